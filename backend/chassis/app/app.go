@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
+	"github.com/freundallein/scheduler/backend/chassis/tracing"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Role - separately scalable part of the scheduler
+type Role struct {
+	Name string
+	// Start launches role's goroutines and returns a function, which applies re-read config
+	Start func(rt *Runtime) (func(*config.AppConfig), error)
+	// Register (optional) adds actions defined in config to the catalog. It's called for every role
+	// of the binary, not only started ones, so e.g. submitter accepts worker's actions.
+	Register func(appCfg *config.AppConfig) error
+}
+
+func usage(roles []Role) {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	fmt.Fprintf(os.Stderr, "usage: %s run --roles=%s [--addr=:2112]\n", os.Args[0], strings.Join(names, ","))
+}
+
+// Main parses command line and runs selected roles until SIGINT/SIGTERM
+func Main(roles ...Role) {
+	if len(os.Args) < 2 || os.Args[1] != "run" {
+		usage(roles)
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	names := flags.String("roles", os.Getenv("SCHEDULER_ROLES"), "comma-separated roles to start")
+	addr := flags.String("addr", ":2112", "metrics and health endpoints address")
+	flags.Parse(os.Args[2:])
+
+	selected := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(*names, ",") {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			fmt.Fprintf(os.Stderr, "duplicated role %q\n", name)
+			usage(roles)
+			os.Exit(2)
+		}
+		seen[name] = true
+		found := false
+		for _, role := range roles {
+			if role.Name == name {
+				selected = append(selected, name)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "unknown role %q\n", name)
+			usage(roles)
+			os.Exit(2)
+		}
+	}
+	Run(*addr, selected, roles...)
+}
+
+// Run starts roles with given names in one process with shared storage pool, queue clients and HTTP port
+func Run(addr string, names []string, all ...Role) {
+	module := strings.Join(names, ",")
+	roles := []Role{}
+	for _, name := range names {
+		for _, role := range all {
+			if role.Name == name {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	appCfg, err := config.Read(names...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event": "config_read_failed",
+		}).Fatal(err)
+	}
+	log.Init(module, appCfg)
+	fault.Init(appCfg)
+	recovery.Init(appCfg)
+	log.WithFields(log.Fields{
+		"event": "init_service",
+	}).Info("service initialized")
+	shutdownTracing, err := tracing.Init(module, tracing.Config{
+		Enabled:     appCfg.Tracing.Enabled,
+		Endpoint:    appCfg.Tracing.Endpoint,
+		Insecure:    appCfg.Tracing.Insecure,
+		SampleRatio: appCfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event": "init_tracing_failed",
+		}).Fatal(err)
+	}
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	var group sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+
+	router := mux.NewRouter()
+	router.Handle("/metrics", promhttp.Handler())
+	router.Handle("/healthz", health.LivenessHandler())
+	router.Handle("/readyz", health.ReadinessHandler())
+	if appCfg.Admin.Enabled {
+		router.Handle("/loglevel", Authorize(appCfg.Admin.Token, log.LevelHandler()))
+		router.Handle("/faults", Authorize(appCfg.Admin.Token, fault.Handler()))
+	}
+
+	for _, role := range all {
+		if role.Register == nil {
+			continue
+		}
+		if err := role.Register(appCfg); err != nil {
+			log.WithFields(log.Fields{
+				"event": "register_role_failed",
+				"role":  role.Name,
+			}).Fatal(err)
+		}
+	}
+	rt := newRuntime(ctx, &group, appCfg, router)
+	reloaders := []func(*config.AppConfig){}
+	for _, role := range roles {
+		reload, err := role.Start(rt)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"event": "init_role_failed",
+				"role":  role.Name,
+			}).Fatal(err)
+		}
+		reloaders = append(reloaders, reload)
+	}
+	go func() {
+		current := appCfg
+		for update := range config.Watch(ctx, names...) {
+			if update.Err == nil {
+				update.Err = config.CheckReload(current, update.Config)
+			}
+			if update.Err != nil {
+				log.WithFields(log.Fields{
+					"event": "config_reload_rejected",
+				}).Error(update.Err)
+				continue
+			}
+			current = update.Config
+			log.Reload(module, current)
+			fault.Init(current)
+			recovery.Init(current)
+			for _, reload := range reloaders {
+				reload(current)
+			}
+			log.WithFields(log.Fields{
+				"event": "config_reloaded",
+			}).Info("config applied")
+		}
+	}()
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("listen: ", err)
+		}
+	}()
+	<-done
+	log.WithFields(log.Fields{
+		"event": "ctx_cancel",
+	}).Info("received syscall")
+	health.Drain()
+	cancel()
+	group.Wait()
+	rt.close()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		log.Error("server shutdown failed: ", err)
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error("tracing shutdown failed: ", err)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/gorilla/mux"
+)
+
+// Runtime - resources shared by all roles of one process
+type Runtime struct {
+	ctx    context.Context
+	group  *sync.WaitGroup
+	config *config.AppConfig
+	router *mux.Router
+
+	mu       sync.Mutex
+	repo     storage.TaskRepository
+	queues   map[string]queue.Client
+	shutdown []func()
+}
+
+func newRuntime(ctx context.Context, group *sync.WaitGroup, appCfg *config.AppConfig, router *mux.Router) *Runtime {
+	return &Runtime{
+		ctx:    ctx,
+		group:  group,
+		config: appCfg,
+		router: router,
+		queues: map[string]queue.Client{},
+	}
+}
+
+// Context is canceled on shutdown
+func (rt *Runtime) Context() context.Context {
+	return rt.ctx
+}
+
+// Group - roles' goroutines are waited with it on shutdown
+func (rt *Runtime) Group() *sync.WaitGroup {
+	return rt.group
+}
+
+// Config - config read at startup
+func (rt *Runtime) Config() *config.AppConfig {
+	return rt.config
+}
+
+// Router - HTTP router of the service port, roles can mount their endpoints
+func (rt *Runtime) Router() *mux.Router {
+	return rt.router
+}
+
+// OnShutdown registers fn, which is called after all roles' goroutines are finished
+func (rt *Runtime) OnShutdown(fn func()) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.shutdown = append(rt.shutdown, fn)
+}
+
+// close calls shutdown functions in reverse order
+func (rt *Runtime) close() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for i := len(rt.shutdown) - 1; i >= 0; i-- {
+		rt.shutdown[i]()
+	}
+}
+
+// Repository returns task repository with one connection pool for all roles
+func (rt *Runtime) Repository() (storage.TaskRepository, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.repo != nil {
+		return rt.repo, nil
+	}
+	repo, err := storage.InitPGRepository(storage.Config{
+		DSN:      rt.config.Storage.DSN,
+		MaxConns: rt.config.Storage.MaxConns,
+	})
+	if err != nil {
+		return nil, err
+	}
+	health.Register("storage", repo.Ping)
+	rt.repo = repo
+	return repo, nil
+}
+
+// DeadLetter returns client of dead-letter queue or nil if it's not configured
+func (rt *Runtime) DeadLetter() queue.Client {
+	if rt.config.Deadletter.Name == "" {
+		return nil
+	}
+	return rt.Queue(rt.config.Deadletter)
+}
+
+// Queue returns client of the queue, roles that use the same queue share it
+func (rt *Runtime) Queue(q config.Queue) queue.Client {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	key := q.URL + "/" + q.Name
+	if client, ok := rt.queues[key]; ok {
+		return client
+	}
+	client := queue.InitAWSQueue(queue.NewConfig(q, rt.config.AWS))
+	health.Register("queue."+q.Name, client.Ping)
+	rt.queues[key] = client
+	return client
+}
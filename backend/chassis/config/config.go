@@ -7,76 +7,196 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// Queue - queue settings, shared by all modules
+type Queue struct {
+	Name    string `yaml:"name"`
+	URL     string `yaml:"url"`
+	Retries int    `yaml:"readRetries" default:"5"`
+}
+
+// AWS - credentials for AWS services
+type AWS struct {
+	Region             string `yaml:"region"`
+	CredentialsFile    string `yaml:"credentialsFile"`
+	CredentialsProfile string `yaml:"credentialsProfile" default:"default"`
+}
+
+// FaultPoint - fault injection settings of a named point
+type FaultPoint struct {
+	Probability float64 `yaml:"probability"`
+	Latency     int     `yaml:"latency"`
+	Drop        float64 `yaml:"drop"`
+	Duplicate   float64 `yaml:"duplicate"`
+}
+
+// Export - SQL-to-SQL export of worker's action, a row selected by key params is copied to target table
+type Export struct {
+	Source struct {
+		DSN   string            `yaml:"dsn"` // worker.resources.db if empty
+		Table string            `yaml:"table"`
+		Key   map[string]string `yaml:"key"` // Key column: task's param
+	}
+	Target struct {
+		DSN   string `yaml:"dsn"` // worker.resources.db if empty
+		Table string `yaml:"table"`
+	}
+	Columns    map[string]string `yaml:"columns"`    // Source column: target column
+	OnConflict string            `yaml:"onConflict"` // skip (default) | upsert
+	BatchSize  int               `yaml:"batchSize"`  // Rows per statement of batch tasks, 1000 if empty
+}
+
+// Webhook - HTTP call of worker's action, templates get task's .ID, .Attempt and .Params
+type Webhook struct {
+	Method  string            `yaml:"method"`  // POST if empty
+	URL     string            `yaml:"url"`     // e.g. "http://svc/objects/{{path .Params.objectID}}"
+	Headers map[string]string `yaml:"headers"` // e.g. {Authorization: "Bearer {{env \"SVC_TOKEN\"}}"}
+	Body    string            `yaml:"body"`    // Params as JSON object if empty
+	Timeout int               `yaml:"timeout"` // Seconds, worker.resources.http.timeout if empty
+	Retry   []int             `yaml:"retry"`   // Retryable statuses besides 408, 429 and 5xx
+}
+
+// Command - allowed executable of worker's action, args and env are templates of task's .ID, .Attempt and .Params
+type Command struct {
+	Path       string            `yaml:"path"`       // Absolute path of executable
+	Args       []string          `yaml:"args"`       // e.g. ["--object", "{{.Params.objectID}}"]
+	Env        map[string]string `yaml:"env"`        // Command's environment besides PATH, TASK_ID and TASK_ATTEMPT
+	InheritEnv []string          `yaml:"inheritEnv"` // Names of worker's variables passed to command, the rest isn't
+	Stdin      bool              `yaml:"stdin"`      // Params as JSON object on stdin
+	Dir        string            `yaml:"dir"`        // Working directory, worker's one if empty
+	Timeout    int               `yaml:"timeout"`    // Seconds, process group is killed after it, worker.timeout if empty
+	Retry      []int             `yaml:"retry"`      // Retryable exit codes besides 75 (EX_TEMPFAIL)
+}
+
 // AppConfig ...
 type AppConfig struct {
 	Storage struct {
-		DSN string `yaml:"dsn"`
+		DSN      string `yaml:"dsn"`
+		MaxConns int    `yaml:"maxConns" default:"100"` // Shared by all roles of the process
 	}
-	AWS struct {
-		Region             string `yaml:"region"`
-		CredentialsFile    string `yaml:"credentialsFile"`
-		CredentialsProfile string `yaml:"credentialsProfile"`
+	AWS AWS
+	// Deadletter - optional queue of messages which can't be processed, without it they stay in their queues
+	Deadletter Queue
+	Logging    struct {
+		Format   string   `yaml:"format" default:"text"`
+		Redact   []string `yaml:"redact"`
+		Sampling struct {
+			Interval int            `yaml:"interval" default:"60"`
+			Events   map[string]int `yaml:"events"`
+		}
+	}
+	Faults struct {
+		Enabled bool                  `yaml:"enabled"`
+		Points  map[string]FaultPoint `yaml:"points"`
+	}
+	Recovery struct {
+		MaxStrikes int `yaml:"maxStrikes" default:"3"` // Panics of a message before it's dead-lettered
+	}
+	// Admin - runtime control endpoints on the metrics port, they aren't served unless enabled
+	Admin struct {
+		Enabled bool   `yaml:"enabled"`
+		Token   string `yaml:"token"` // Bearer token of requests, no auth if empty
+	}
+	Tracing struct {
+		Enabled     bool    `yaml:"enabled"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		SampleRatio float64 `yaml:"sampleRatio" default:"1"`
+	}
+	// Protocol - wire format of produced messages, consumers accept every version.
+	// Switch it to 1 after all consumers are deployed with v1 support.
+	Protocol struct {
+		Version int `yaml:"version" default:"0"` // 0 - JSON-RPC 2.0, 1 - v1 envelope
 	}
 	Submitter struct {
-		Queuesrc struct {
-			Name    string `yaml:"name"`
-			URL     string `yaml:"url"`
-			Retries int    `yaml:"readRetries"`
+		Queuesrc    Queue
+		Workers     int    `yaml:"workers" default:"20"`
+		LogLevel    string `yaml:"loglevel" default:"info"`
+		Idempotency struct {
+			Window int                 `yaml:"window" default:"3600"`
+			Fields map[string][]string `yaml:"fields"`
+		}
+		// API - HTTP API of tasks on the metrics port, /api/v1/tasks, it isn't served unless enabled
+		API struct {
+			Enabled bool   `yaml:"enabled"`
+			Token   string `yaml:"token"` // Bearer token of requests, required when enabled
 		}
-		Workers  int    `yaml:"workers"`
-		LogLevel string `yaml:"loglevel"`
 	}
 	Scheduler struct {
-		Queuedst struct {
-			Name    string `yaml:"name"`
-			URL     string `yaml:"url"`
-			Retries int    `yaml:"readRetries"`
-		}
-		Workers  int    `yaml:"workers"`
-		LogLevel string `yaml:"loglevel"`
+		Queuedst Queue
+		Workers  int    `yaml:"workers" default:"5"`
+		LogLevel string `yaml:"loglevel" default:"info"`
 	}
 	Worker struct {
-		Queuesrc struct {
-			Name    string `yaml:"name"`
-			URL     string `yaml:"url"`
-			Retries int    `yaml:"readRetries"`
-		}
-		Queuedst struct {
-			Name    string `yaml:"name"`
-			URL     string `yaml:"url"`
-			Retries int    `yaml:"readRetries"`
+		Queuesrc Queue
+		Queuedst Queue
+		Workers  int                `yaml:"workers" default:"20"`
+		LogLevel string             `yaml:"loglevel" default:"info"`
+		Timeout  int                `yaml:"timeout" default:"60"` // Seconds, 0 - no limit
+		Timeouts map[string]int     `yaml:"timeouts"`             // Per-action timeouts, seconds
+		Exports  map[string]Export  `yaml:"exports"`              // Export jobs by action name
+		Webhooks map[string]Webhook `yaml:"webhooks"`             // HTTP calls by action name
+		Commands map[string]Command `yaml:"commands"`             // Allow-list of executables by action name
+		// Resources - clients shared by handlers
+		Resources struct {
+			DB struct {
+				DSN      string `yaml:"dsn"` // storage.dsn if empty
+				MaxConns int    `yaml:"maxConns" default:"20"`
+			}
+			HTTP struct {
+				Timeout         int `yaml:"timeout" default:"30"` // Seconds
+				MaxIdleConns    int `yaml:"maxIdleConns" default:"100"`
+				MaxConnsPerHost int `yaml:"maxConnsPerHost" default:"0"` // 0 - no limit
+			}
 		}
-		Workers  int    `yaml:"workers"`
-		LogLevel string `yaml:"loglevel"`
 	}
 	Resulter struct {
-		Queuesrc struct {
-			Name    string `yaml:"name"`
-			URL     string `yaml:"url"`
-			Retries int    `yaml:"readRetries"`
+		Queuesrc Queue
+		Workers  int    `yaml:"workers" default:"20"`
+		LogLevel string `yaml:"loglevel" default:"info"`
+		// Callbacks - delivery of finished tasks' results to callbacks of submits
+		Callbacks struct {
+			Workers     int      `yaml:"workers" default:"2"`
+			Secret      string   `yaml:"secret"`                   // HMAC-SHA256 key of X-Scheduler-Signature, unsigned if empty
+			Timeout     int      `yaml:"timeout" default:"10"`     // Seconds
+			MaxAttempts int      `yaml:"maxAttempts" default:"10"` // Then delivery is FAILED
+			BatchSize   int      `yaml:"batchSize" default:"10"`   // Deliveries taken at once by notifier
+			Interval    int      `yaml:"interval" default:"1"`     // Seconds between polls of empty outbox
+			QueueURL    string   `yaml:"queueURL"`                 // Base URL of reply queues
+			Queues      []string `yaml:"queues"`                   // Allowed reply queues
+			Origins     []string `yaml:"origins"`                  // Allowed scheme and host of callback URLs, e.g. https://objects
 		}
-		Workers  int    `yaml:"workers"`
-		LogLevel string `yaml:"loglevel"`
 	}
 	Supervisor struct {
-		Workers         int    `yaml:"workers"`
-		LogLevel        string `yaml:"loglevel"`
-		StaleTimeout    int    `yaml:"staleTimeout"`
-		RepairBatchSize int    `yaml:"repairBatchSize"`
-		Expiration      int    `yaml:"expiration"`
+		Workers         int    `yaml:"workers" default:"1"`
+		LogLevel        string `yaml:"loglevel" default:"info"`
+		StaleTimeout    int    `yaml:"staleTimeout" default:"120"`
+		RepairBatchSize int    `yaml:"repairBatchSize" default:"10"`
+		Expiration      int    `yaml:"expiration" default:"3600"`
+		Interval        int    `yaml:"interval" default:"5"`
 	}
 }
 
-// Read ...
-func Read() (*AppConfig, error) {
-	filename := os.Getenv("CFG_PATH")
+// Read loads config for modules: defaults, then YAML file from CFG_PATH (optional),
+// then SCHEDULER_* environment variables. Result is validated for given modules.
+func Read(modules ...string) (*AppConfig, error) {
 	cfg := &AppConfig{}
-	buff, err := ioutil.ReadFile(filename)
-	if err != nil {
+	if err := applyDefaults(cfg); err != nil {
+		return nil, err
+	}
+	if filename := os.Getenv("CFG_PATH"); filename != "" {
+		buff, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(buff, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
 		return nil, err
 	}
-	err = yaml.Unmarshal(buff, cfg)
-	if err != nil {
+	if err := cfg.Validate(modules...); err != nil {
 		return nil, err
 	}
 	return cfg, nil
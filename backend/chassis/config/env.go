@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	envPrefix = "SCHEDULER"
+)
+
+// fieldName returns YAML key of a struct field
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// EnvName converts YAML path to environment variable,
+// e.g. `supervisor.staleTimeout` -> `SCHEDULER_SUPERVISOR_STALE_TIMEOUT`
+func EnvName(path ...string) string {
+	parts := []string{envPrefix}
+	for _, key := range path {
+		var snake []rune
+		runes := []rune(key)
+		for i, r := range runes {
+			if unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]) {
+				snake = append(snake, '_')
+			}
+			snake = append(snake, unicode.ToUpper(r))
+		}
+		parts = append(parts, string(snake))
+	}
+	return strings.Join(parts, "_")
+}
+
+// walk calls fn for every non-struct field of v with its YAML path
+func walk(v reflect.Value, path []string, fn func(value reflect.Value, field reflect.StructField, path []string) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		fieldPath := append(append([]string{}, path...), fieldName(field))
+		if value.Kind() == reflect.Struct {
+			if err := walk(value, fieldPath, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(value, field, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set parses raw string into value, lists and maps are expected in YAML flow style,
+// e.g. `[email, phone]` or `{export: [objectID]}`
+func set(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Slice, reflect.Map:
+		parsed := reflect.New(value.Type())
+		if err := yaml.Unmarshal([]byte(raw), parsed.Interface()); err != nil {
+			return err
+		}
+		value.Set(parsed.Elem())
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func applyDefaults(cfg *AppConfig) error {
+	return walk(reflect.ValueOf(cfg).Elem(), nil, func(value reflect.Value, field reflect.StructField, path []string) error {
+		raw, ok := field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		if err := set(value, raw); err != nil {
+			return fmt.Errorf("%s: bad default %q: %s", strings.Join(path, "."), raw, err)
+		}
+		return nil
+	})
+}
+
+func applyEnv(cfg *AppConfig) error {
+	return walk(reflect.ValueOf(cfg).Elem(), nil, func(value reflect.Value, field reflect.StructField, path []string) error {
+		name := EnvName(path...)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		if err := set(value, raw); err != nil {
+			return fmt.Errorf("%s: bad value %q: %s", name, raw, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{path: []string{"storage", "dsn"}, want: "SCHEDULER_STORAGE_DSN"},
+		{path: []string{"supervisor", "staleTimeout"}, want: "SCHEDULER_SUPERVISOR_STALE_TIMEOUT"},
+		{path: []string{"submitter", "queuesrc", "readRetries"}, want: "SCHEDULER_SUBMITTER_QUEUESRC_READ_RETRIES"},
+		{path: []string{"resulter", "callbacks", "queueURL"}, want: "SCHEDULER_RESULTER_CALLBACKS_QUEUE_URL"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := EnvName(test.path...); got != test.want {
+				t.Errorf("EnvName(%v) = %s, want %s", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		value   string
+		get     func(cfg *AppConfig) interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:  "string",
+			env:   "SCHEDULER_STORAGE_DSN",
+			value: "postgres://db",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Storage.DSN },
+			want:  "postgres://db",
+		},
+		{
+			name:  "int of nested queue",
+			env:   "SCHEDULER_WORKER_QUEUESRC_READ_RETRIES",
+			value: "7",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Worker.Queuesrc.Retries },
+			want:  7,
+		},
+		{
+			name:  "float",
+			env:   "SCHEDULER_TRACING_SAMPLE_RATIO",
+			value: "0.25",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Tracing.SampleRatio },
+			want:  0.25,
+		},
+		{
+			name:  "bool",
+			env:   "SCHEDULER_ADMIN_ENABLED",
+			value: "true",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Admin.Enabled },
+			want:  true,
+		},
+		{
+			name:  "list",
+			env:   "SCHEDULER_LOGGING_REDACT",
+			value: "[email, phone]",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Logging.Redact },
+			want:  []string{"email", "phone"},
+		},
+		{
+			name:  "map",
+			env:   "SCHEDULER_SUBMITTER_IDEMPOTENCY_FIELDS",
+			value: "{export: [objectID]}",
+			get:   func(cfg *AppConfig) interface{} { return cfg.Submitter.Idempotency.Fields },
+			want:  map[string][]string{"export": {"objectID"}},
+		},
+		{
+			name:    "bad int",
+			env:     "SCHEDULER_SCHEDULER_WORKERS",
+			value:   "many",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv(test.env, test.value)
+			defer os.Unsetenv(test.env)
+			cfg := &AppConfig{}
+			err := applyEnv(cfg)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if got := test.get(cfg); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s = %v, want %v", test.env, got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	cfg := &AppConfig{}
+	if err := applyDefaults(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Worker.Workers != 20 || cfg.Worker.Queuesrc.Retries != 5 || cfg.Tracing.SampleRatio != 1 || cfg.Submitter.API.Enabled {
+		t.Errorf("defaults aren't applied: %+v", cfg)
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	logLevels   = []string{"debug", "info", "warn", "warning", "error"}
+	logFormats  = []string{"text", "json"}
+	httpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+)
+
+// validator collects every problem, so all of them are reported at once
+type validator struct {
+	problems []string
+}
+
+// mapPaths - map sections, their entries have no own environment variables
+var mapPaths = []string{"faults.points.", "worker.timeouts.", "worker.exports.", "worker.webhooks.", "worker.commands."}
+
+func (v *validator) fail(path string, format string, args ...interface{}) {
+	problem := fmt.Sprintf(format, args...)
+	for _, prefix := range mapPaths {
+		if strings.HasPrefix(path, prefix) {
+			// Map entries are overridden by the whole map, e.g. SCHEDULER_FAULTS_POINTS
+			v.problems = append(v.problems, fmt.Sprintf("%s: %s", path, problem))
+			return
+		}
+	}
+	key := strings.Split(path, ".")
+	v.problems = append(v.problems, fmt.Sprintf("%s (%s): %s", path, EnvName(key...), problem))
+}
+
+func (v *validator) required(path string, value string) {
+	if value == "" {
+		v.fail(path, "is required")
+	}
+}
+
+func (v *validator) atLeast(path string, value int, min int) {
+	if value < min {
+		v.fail(path, "must be at least %d, got %d", min, value)
+	}
+}
+
+func (v *validator) ratio(path string, value float64) {
+	if value < 0 || value > 1 {
+		v.fail(path, "must be within [0, 1], got %v", value)
+	}
+}
+
+func (v *validator) oneOf(path string, value string, allowed []string) {
+	for _, option := range allowed {
+		if value == option {
+			return
+		}
+	}
+	v.fail(path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) queue(path string, q Queue) {
+	v.required(path+".name", q.Name)
+	v.required(path+".url", q.URL)
+	v.atLeast(path+".readRetries", q.Retries, 0)
+}
+
+func (v *validator) export(path string, export Export) {
+	v.required(path+".source.table", export.Source.Table)
+	v.required(path+".target.table", export.Target.Table)
+	if len(export.Source.Key) == 0 {
+		v.fail(path+".source.key", "is required")
+	}
+	if len(export.Columns) == 0 {
+		v.fail(path+".columns", "is required")
+	}
+	for column := range export.Source.Key {
+		if _, ok := export.Columns[column]; !ok {
+			v.fail(path+".source.key", "column %q must be exported in columns", column)
+		}
+	}
+	v.atLeast(path+".batchSize", export.BatchSize, 0)
+	if export.OnConflict != "" {
+		v.oneOf(path+".onConflict", export.OnConflict, []string{"skip", "upsert"})
+	}
+}
+
+func (v *validator) webhook(path string, webhook Webhook) {
+	v.required(path+".url", webhook.URL)
+	if webhook.Method != "" {
+		v.oneOf(path+".method", webhook.Method, httpMethods)
+	}
+	v.atLeast(path+".timeout", webhook.Timeout, 0)
+}
+
+func (v *validator) command(path string, command Command) {
+	v.required(path+".path", command.Path)
+	if command.Path != "" && !filepath.IsAbs(command.Path) {
+		v.fail(path+".path", "must be absolute, got %q", command.Path)
+	}
+	v.atLeast(path+".timeout", command.Timeout, 0)
+}
+
+func (v *validator) origin(path string, origin string) {
+	target, err := url.Parse(origin)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" || strings.Trim(target.Path, "/") != "" {
+		v.fail(path, "must be scheme and host, e.g. https://objects, got %q", origin)
+	}
+}
+
+func (v *validator) module(path string, workers int, logLevel string) {
+	v.atLeast(path+".workers", workers, 1)
+	v.oneOf(path+".loglevel", logLevel, logLevels)
+}
+
+// Validate checks shared sections and sections of given modules
+func (cfg *AppConfig) Validate(modules ...string) error {
+	v := &validator{}
+	v.oneOf("logging.format", cfg.Logging.Format, logFormats)
+	v.atLeast("logging.sampling.interval", cfg.Logging.Sampling.Interval, 1)
+	for name, point := range cfg.Faults.Points {
+		v.ratio("faults.points."+name+".probability", point.Probability)
+		v.ratio("faults.points."+name+".drop", point.Drop)
+		v.ratio("faults.points."+name+".duplicate", point.Duplicate)
+		v.atLeast("faults.points."+name+".latency", point.Latency, 0)
+	}
+	if cfg.Tracing.Enabled {
+		v.required("tracing.endpoint", cfg.Tracing.Endpoint)
+	}
+	v.ratio("tracing.sampleRatio", cfg.Tracing.SampleRatio)
+	if cfg.Protocol.Version < 0 || cfg.Protocol.Version > 1 {
+		v.fail("protocol.version", "must be 0 or 1, got %d", cfg.Protocol.Version)
+	}
+	v.atLeast("storage.maxConns", cfg.Storage.MaxConns, 1)
+	v.atLeast("recovery.maxStrikes", cfg.Recovery.MaxStrikes, 1)
+	if cfg.Deadletter.Name != "" {
+		v.queue("deadletter", cfg.Deadletter)
+	}
+
+	usesQueue := false
+	for _, module := range modules {
+		switch module {
+		case "submitter":
+			v.required("storage.dsn", cfg.Storage.DSN)
+			v.queue("submitter.queuesrc", cfg.Submitter.Queuesrc)
+			v.module("submitter", cfg.Submitter.Workers, cfg.Submitter.LogLevel)
+			v.atLeast("submitter.idempotency.window", cfg.Submitter.Idempotency.Window, 0)
+			if cfg.Submitter.API.Enabled {
+				// Submits of the API choose callback URLs, they must not be open to anyone
+				v.required("submitter.api.token", cfg.Submitter.API.Token)
+			}
+			usesQueue = true
+		case "scheduler":
+			v.required("storage.dsn", cfg.Storage.DSN)
+			v.queue("scheduler.queuedst", cfg.Scheduler.Queuedst)
+			v.module("scheduler", cfg.Scheduler.Workers, cfg.Scheduler.LogLevel)
+			usesQueue = true
+		case "worker":
+			v.queue("worker.queuesrc", cfg.Worker.Queuesrc)
+			v.queue("worker.queuedst", cfg.Worker.Queuedst)
+			v.module("worker", cfg.Worker.Workers, cfg.Worker.LogLevel)
+			v.atLeast("worker.timeout", cfg.Worker.Timeout, 0)
+			v.atLeast("worker.resources.db.maxConns", cfg.Worker.Resources.DB.MaxConns, 1)
+			v.atLeast("worker.resources.http.timeout", cfg.Worker.Resources.HTTP.Timeout, 1)
+			v.atLeast("worker.resources.http.maxIdleConns", cfg.Worker.Resources.HTTP.MaxIdleConns, 0)
+			v.atLeast("worker.resources.http.maxConnsPerHost", cfg.Worker.Resources.HTTP.MaxConnsPerHost, 0)
+			for action, export := range cfg.Worker.Exports {
+				v.export("worker.exports."+action, export)
+			}
+			for action, webhook := range cfg.Worker.Webhooks {
+				v.webhook("worker.webhooks."+action, webhook)
+				if _, ok := cfg.Worker.Exports[action]; ok {
+					v.fail("worker.webhooks."+action, "action is already defined in worker.exports")
+				}
+			}
+			for action, command := range cfg.Worker.Commands {
+				v.command("worker.commands."+action, command)
+				_, isExport := cfg.Worker.Exports[action]
+				_, isWebhook := cfg.Worker.Webhooks[action]
+				if isExport || isWebhook {
+					v.fail("worker.commands."+action, "action is already defined in worker.exports or worker.webhooks")
+				}
+			}
+			for action, timeout := range cfg.Worker.Timeouts {
+				v.atLeast("worker.timeouts."+action, timeout, 0)
+			}
+			usesQueue = true
+		case "resulter":
+			v.required("storage.dsn", cfg.Storage.DSN)
+			v.queue("resulter.queuesrc", cfg.Resulter.Queuesrc)
+			v.module("resulter", cfg.Resulter.Workers, cfg.Resulter.LogLevel)
+			v.atLeast("resulter.callbacks.workers", cfg.Resulter.Callbacks.Workers, 0)
+			v.atLeast("resulter.callbacks.timeout", cfg.Resulter.Callbacks.Timeout, 1)
+			v.atLeast("resulter.callbacks.maxAttempts", cfg.Resulter.Callbacks.MaxAttempts, 1)
+			v.atLeast("resulter.callbacks.batchSize", cfg.Resulter.Callbacks.BatchSize, 1)
+			v.atLeast("resulter.callbacks.interval", cfg.Resulter.Callbacks.Interval, 1)
+			if len(cfg.Resulter.Callbacks.Queues) > 0 {
+				v.required("resulter.callbacks.queueURL", cfg.Resulter.Callbacks.QueueURL)
+			}
+			for _, origin := range cfg.Resulter.Callbacks.Origins {
+				v.origin("resulter.callbacks.origins", origin)
+			}
+			usesQueue = true
+		case "supervisor":
+			v.required("storage.dsn", cfg.Storage.DSN)
+			v.module("supervisor", cfg.Supervisor.Workers, cfg.Supervisor.LogLevel)
+			v.atLeast("supervisor.staleTimeout", cfg.Supervisor.StaleTimeout, 1)
+			v.atLeast("supervisor.repairBatchSize", cfg.Supervisor.RepairBatchSize, 1)
+			v.atLeast("supervisor.expiration", cfg.Supervisor.Expiration, 1)
+			v.atLeast("supervisor.interval", cfg.Supervisor.Interval, 1)
+		}
+	}
+	if usesQueue {
+		v.required("aws.region", cfg.AWS.Region)
+	}
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid config:\n  " + strings.Join(dedup(v.problems), "\n  "))
+}
+
+func dedup(problems []string) []string {
+	seen := map[string]bool{}
+	unique := problems[:0]
+	for _, problem := range problems {
+		if seen[problem] {
+			continue
+		}
+		seen[problem] = true
+		unique = append(unique, problem)
+	}
+	return unique
+}
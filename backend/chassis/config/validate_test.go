@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSubmitterAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		token   string
+		wantErr bool
+	}{
+		{name: "disabled"},
+		{name: "enabled with token", enabled: true, token: "secret"},
+		{name: "enabled without token", enabled: true, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &AppConfig{}
+			if err := applyDefaults(cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg.Storage.DSN = "postgres://db"
+			cfg.Submitter.Queuesrc = Queue{Name: "inbound", URL: "http://queue"}
+			cfg.AWS.Region = "eu-central-1"
+			cfg.Submitter.API.Enabled = test.enabled
+			cfg.Submitter.API.Token = test.token
+			err := cfg.Validate("submitter")
+			if test.wantErr != (err != nil && strings.Contains(err.Error(), "submitter.api.token")) {
+				t.Errorf("error = %v, want token error %v", err, test.wantErr)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("error = %v, want nil", err)
+			}
+		})
+	}
+}
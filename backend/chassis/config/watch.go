@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	watchInterval = 5 * time.Second
+)
+
+// live - fields which can be changed without restart, `*` matches any module
+var live = []string{
+	"*.workers",
+	"resulter.callbacks.workers",
+	"*.loglevel",
+	"faults",
+	"recovery",
+	"supervisor.staleTimeout",
+	"supervisor.repairBatchSize",
+	"supervisor.expiration",
+	"supervisor.interval",
+}
+
+// Update - re-read config or error
+type Update struct {
+	Config *AppConfig
+	Err    error
+}
+
+func modified(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Watch re-reads config on SIGHUP or when file at CFG_PATH is modified, until ctx is canceled
+func Watch(ctx context.Context, modules ...string) <-chan Update {
+	updates := make(chan Update)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer close(updates)
+		defer signal.Stop(hup)
+		filename := os.Getenv("CFG_PATH")
+		lastModified := modified(filename)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			case <-ticker.C:
+				if filename == "" {
+					continue
+				}
+				current := modified(filename)
+				if current.Equal(lastModified) {
+					continue
+				}
+				lastModified = current
+			}
+			cfg, err := Read(modules...)
+			select {
+			case updates <- Update{Config: cfg, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+func isLive(path []string) bool {
+	for _, pattern := range live {
+		parts := strings.Split(pattern, ".")
+		if len(parts) > len(path) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if part != "*" && part != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func diff(prev, next reflect.Value, path []string, changed *[]string) {
+	if isLive(path) {
+		return
+	}
+	if prev.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(prev.Interface(), next.Interface()) {
+			*changed = append(*changed, strings.Join(path, "."))
+		}
+		return
+	}
+	for i := 0; i < prev.NumField(); i++ {
+		fieldPath := append(append([]string{}, path...), fieldName(prev.Type().Field(i)))
+		diff(prev.Field(i), next.Field(i), fieldPath, changed)
+	}
+}
+
+// CheckReload returns FrozenError, if next config changes fields, which can't be changed at runtime
+func CheckReload(prev, next *AppConfig) error {
+	changed := []string{}
+	diff(reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem(), nil, &changed)
+	if len(changed) > 0 {
+		return &FrozenError{Fields: changed}
+	}
+	return nil
+}
+
+// FrozenError - reload is rejected as a whole
+type FrozenError struct {
+	Fields []string
+}
+
+func (e *FrozenError) Error() string {
+	return fmt.Sprintf("fields can't be changed at runtime, restart is required: %s", strings.Join(e.Fields, ", "))
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckReload(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *AppConfig)
+		frozen []string
+	}{
+		{
+			name:   "nothing",
+			change: func(cfg *AppConfig) {},
+		},
+		{
+			name:   "workers of any module",
+			change: func(cfg *AppConfig) { cfg.Worker.Workers = 50; cfg.Resulter.Workers = 1 },
+		},
+		{
+			name:   "callback workers",
+			change: func(cfg *AppConfig) { cfg.Resulter.Callbacks.Workers = 5 },
+		},
+		{
+			name:   "log level",
+			change: func(cfg *AppConfig) { cfg.Scheduler.LogLevel = "debug" },
+		},
+		{
+			name: "faults",
+			change: func(cfg *AppConfig) {
+				cfg.Faults.Enabled = true
+				cfg.Faults.Points = map[string]FaultPoint{"worker.webhook.call": {}}
+			},
+		},
+		{
+			name:   "supervisor's live fields",
+			change: func(cfg *AppConfig) { cfg.Supervisor.StaleTimeout = 60; cfg.Supervisor.Interval = 1 },
+		},
+		{
+			name:   "storage",
+			change: func(cfg *AppConfig) { cfg.Storage.DSN = "postgres://other" },
+			frozen: []string{"storage.dsn"},
+		},
+		{
+			name: "nested and map fields",
+			change: func(cfg *AppConfig) {
+				cfg.Worker.Queuesrc.URL = "http://queue"
+				cfg.Worker.Timeouts = map[string]int{"export": 10}
+				cfg.Resulter.Callbacks.Timeout = 5
+			},
+			frozen: []string{"worker.queuesrc.url", "worker.timeouts", "resulter.callbacks.timeout"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prev := &AppConfig{}
+			if err := applyDefaults(prev); err != nil {
+				t.Fatal(err)
+			}
+			next := &AppConfig{}
+			if err := applyDefaults(next); err != nil {
+				t.Fatal(err)
+			}
+			test.change(next)
+			err := CheckReload(prev, next)
+			if test.frozen == nil {
+				if err != nil {
+					t.Errorf("error = %v, want nil", err)
+				}
+				return
+			}
+			var frozenErr *FrozenError
+			if !errors.As(err, &frozenErr) {
+				t.Fatalf("error = %v, want FrozenError", err)
+			}
+			if !reflect.DeepEqual(frozenErr.Fields, test.frozen) {
+				t.Errorf("fields = %v, want %v", frozenErr.Fields, test.frozen)
+			}
+		})
+	}
+}
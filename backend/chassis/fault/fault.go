@@ -0,0 +1,176 @@
+package fault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+)
+
+// ErrInjected - base error of every injected fault
+var ErrInjected = errors.New("injected fault")
+
+// Point - faults of a named injection point, e.g. `scheduler.send`
+type Point struct {
+	Probability float64 `json:"probability"` // chance of an injected error
+	Latency     int     `json:"latency"`     // milliseconds of delay before the operation
+	Drop        float64 `json:"drop"`        // chance that a message is silently not sent
+	Duplicate   float64 `json:"duplicate"`   // chance that a message is sent twice
+}
+
+var (
+	mu      sync.RWMutex
+	enabled bool
+	points  = map[string]Point{}
+
+	rndMu sync.Mutex
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Init replaces injection points. Faults are disabled unless `faults.enabled` is set.
+func Init(appCfg *config.AppConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = appCfg.Faults.Enabled
+	points = map[string]Point{}
+	for name, point := range appCfg.Faults.Points {
+		points[name] = Point(point)
+	}
+	if enabled {
+		log.WithFields(log.Fields{
+			"event":  "faults_enabled",
+			"points": len(points),
+		}).Warn("fault injection is enabled")
+	}
+}
+
+// Enable toggles fault injection at runtime
+func Enable(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = on
+}
+
+func lookup(name string) (Point, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !enabled {
+		return Point{}, false
+	}
+	point, ok := points[name]
+	return point, ok
+}
+
+func chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	rndMu.Lock()
+	defer rndMu.Unlock()
+	return rnd.Float64() < probability
+}
+
+// Inject returns err as is, otherwise it may delay and return an injected error for the point
+func Inject(name string, err error) error {
+	if err != nil {
+		return err
+	}
+	point, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+	if point.Latency > 0 {
+		time.Sleep(time.Duration(point.Latency) * time.Millisecond)
+	}
+	if chance(point.Probability) {
+		return fmt.Errorf("%w: %s", ErrInjected, name)
+	}
+	return nil
+}
+
+// Send wraps message delivery, so the point can drop, duplicate, delay or fail it
+func Send(name string, send func() error) error {
+	point, ok := lookup(name)
+	if !ok {
+		return send()
+	}
+	if point.Latency > 0 {
+		time.Sleep(time.Duration(point.Latency) * time.Millisecond)
+	}
+	if chance(point.Probability) {
+		return fmt.Errorf("%w: %s", ErrInjected, name)
+	}
+	if chance(point.Drop) {
+		log.WithFields(log.Fields{
+			"event": "fault_drop",
+			"point": name,
+		}).Warn("message dropped")
+		return nil
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	if chance(point.Duplicate) {
+		log.WithFields(log.Fields{
+			"event": "fault_duplicate",
+			"point": name,
+		}).Warn("message duplicated")
+		return send()
+	}
+	return nil
+}
+
+type state struct {
+	Enabled bool             `json:"enabled"`
+	Points  map[string]Point `json:"points"`
+}
+
+// Handler reports faults on GET, toggles them on PUT with `enabled` query param
+// and replaces injection points on POST with JSON body, e.g.
+// `curl -X POST localhost:2112/faults -d '{"scheduler.send": {"probability": 0.1}}'`
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			on, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Enable(on)
+			log.WithFields(log.Fields{
+				"event":   "faults_toggled",
+				"enabled": on,
+			}).Warn("fault injection toggled")
+		case http.MethodPost:
+			update := map[string]Point{}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			points = update
+			mu.Unlock()
+			log.WithFields(log.Fields{
+				"event":  "faults_updated",
+				"points": len(update),
+			}).Warn("fault injection points replaced")
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.RLock()
+		current := state{Enabled: enabled, Points: points}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(current)
+		mu.RUnlock()
+	})
+}
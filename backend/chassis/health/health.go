@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	checkTimeout = 5 * time.Second
+)
+
+// StaleAfter - worker loop without heartbeat for that long is considered stuck, unless heartbeat allows more
+var StaleAfter = 2 * time.Minute
+
+// Check - dependency probe, nil means dependency is reachable
+type Check func(ctx context.Context) error
+
+var (
+	mu       sync.RWMutex
+	checks   = map[string]Check{}
+	beats    = map[string]beat{}
+	draining int32
+)
+
+// Register adds a readiness check
+func Register(name string, check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks[name] = check
+}
+
+// beat - the last loop timestamp and time allowed until the next one, zero - no limit
+type beat struct {
+	at     time.Time
+	within time.Duration
+}
+
+// Beat records the last loop timestamp of module's worker goroutine, the next one is expected within StaleAfter
+func Beat(module string, workerID interface{}) {
+	BeatWithin(module, workerID, StaleAfter)
+}
+
+// BeatWithin records the last loop timestamp, when goroutine's next step may take longer than StaleAfter,
+// e.g. handler with a long timeout or loop with a long interval. Zero means no limit.
+func BeatWithin(module string, workerID interface{}, within time.Duration) {
+	key := fmt.Sprintf("%s-%v", module, workerID)
+	mu.Lock()
+	defer mu.Unlock()
+	beats[key] = beat{at: time.Now(), within: within}
+}
+
+// Forget removes heartbeat of exited worker goroutine
+func Forget(module string, workerID interface{}) {
+	key := fmt.Sprintf("%s-%v", module, workerID)
+	mu.Lock()
+	defer mu.Unlock()
+	delete(beats, key)
+}
+
+// Drain marks service as shutting down, so it is not ready anymore
+func Drain() {
+	atomic.StoreInt32(&draining, 1)
+}
+
+// Draining ...
+func Draining() bool {
+	return atomic.LoadInt32(&draining) == 1
+}
+
+type report struct {
+	Status  string            `json:"status"`
+	Workers map[string]string `json:"workers,omitempty"`
+	Checks  map[string]string `json:"checks,omitempty"`
+}
+
+func write(w http.ResponseWriter, rep *report) {
+	w.Header().Set("Content-Type", "application/json")
+	if rep.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(rep)
+}
+
+// LivenessHandler reports worker goroutines' last loop timestamps and fails if any of them is stuck
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rep := &report{
+			Status:  "ok",
+			Workers: map[string]string{},
+		}
+		mu.RLock()
+		for key, beat := range beats {
+			rep.Workers[key] = beat.at.Format(time.RFC3339)
+			if beat.within > 0 && time.Since(beat.at) > beat.within {
+				rep.Status = "stuck"
+			}
+		}
+		mu.RUnlock()
+		write(w, rep)
+	})
+}
+
+// ReadinessHandler runs registered checks and fails while service is draining
+func ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rep := &report{
+			Status: "ok",
+			Checks: map[string]string{},
+		}
+		if Draining() {
+			rep.Status = "draining"
+			write(w, rep)
+			return
+		}
+		mu.RLock()
+		probes := make(map[string]Check, len(checks))
+		for name, check := range checks {
+			probes[name] = check
+		}
+		mu.RUnlock()
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+		for name, check := range probes {
+			if err := check(ctx); err != nil {
+				rep.Status = "unavailable"
+				rep.Checks[name] = err.Error()
+				continue
+			}
+			rep.Checks[name] = "ok"
+		}
+		write(w, rep)
+	})
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLiveness(t *testing.T) {
+	tests := []struct {
+		name   string
+		beat   beat
+		status int
+	}{
+		{name: "fresh", beat: beat{at: time.Now(), within: StaleAfter}, status: http.StatusOK},
+		{name: "stale", beat: beat{at: time.Now().Add(-StaleAfter - time.Second), within: StaleAfter}, status: http.StatusServiceUnavailable},
+		{name: "long handler", beat: beat{at: time.Now().Add(-StaleAfter - time.Second), within: 10 * time.Minute}, status: http.StatusOK},
+		{name: "no limit", beat: beat{at: time.Now().Add(-24 * time.Hour)}, status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mu.Lock()
+			beats = map[string]beat{"worker-1": test.beat}
+			mu.Unlock()
+			recorder := httptest.NewRecorder()
+			LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestBeatWithin(t *testing.T) {
+	BeatWithin("worker", 2, time.Minute)
+	defer Forget("worker", 2)
+	mu.RLock()
+	got := beats["worker-2"]
+	mu.RUnlock()
+	if got.within != time.Minute || time.Since(got.at) > time.Second {
+		t.Errorf("beat = %+v, want within 1m now", got)
+	}
+	Beat("worker", 2)
+	mu.RLock()
+	got = beats["worker-2"]
+	mu.RUnlock()
+	if got.within != StaleAfter {
+		t.Errorf("within = %s, want %s", got.within, StaleAfter)
+	}
+}
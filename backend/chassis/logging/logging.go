@@ -1,7 +1,12 @@
 package logging
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/freundallein/scheduler/backend/chassis/config"
 	"github.com/sirupsen/logrus"
@@ -11,36 +16,149 @@ const (
 	timeFormat = "2006-01-02 15:04:05"
 )
 
-var logger = logrus.NewEntry(logrus.New())
+// Standard field names, shared by all modules
+const (
+	FieldModule  = "module"
+	FieldEvent   = "event"
+	FieldWorker  = "worker"
+	FieldTaskID  = "taskID"
+	FieldAction  = "action"
+	FieldAttempt = "attempt"
+)
+
+// standardFields are always rendered as strings, so JSON logs have the same type for a key in every module
+var standardFields = []string{FieldWorker, FieldTaskID, FieldAction, FieldAttempt}
+
+var (
+	logger = logrus.NewEntry(logrus.New())
+	rules  = newRedactor(nil)
+	events = newSampler(nil)
+)
 
 // Fields ...
 type Fields logrus.Fields
 
-// Init ...
-func Init(module string, appCfg *config.AppConfig) {
-	customFormatter := &logrus.TextFormatter{}
-	customFormatter.TimestampFormat = timeFormat
-	customFormatter.FullTimestamp = true
-	logrus.SetFormatter(customFormatter)
-	logrus.SetOutput(os.Stdout)
-	switch appCfg.Submitter.LogLevel { // TODO: use reflect to get module's loglevel
+// moduleLevel returns log level of module's config section. Load generator (cmd/test) runs with
+// submitter's config and feeds its queue, so it uses submitter's level. Other tools log at info.
+func moduleLevel(module string, appCfg *config.AppConfig) string {
+	switch module {
+	case "submitter", "test":
+		return appCfg.Submitter.LogLevel
+	case "scheduler":
+		return appCfg.Scheduler.LogLevel
+	case "worker":
+		return appCfg.Worker.LogLevel
+	case "resulter":
+		return appCfg.Resulter.LogLevel
+	case "supervisor":
+		return appCfg.Supervisor.LogLevel
+	default:
+		return "info"
+	}
+}
+
+// processLevel returns the most verbose level of comma-separated modules running in one process:
+// logger is shared by roles of a process, so e.g. worker's debug level applies to submitter as well
+func processLevel(module string, appCfg *config.AppConfig) logrus.Level {
+	level := logrus.PanicLevel
+	for _, name := range strings.Split(module, ",") {
+		if current := parseLevel(moduleLevel(name, appCfg)); current > level {
+			level = current
+		}
+	}
+	return level
+}
+
+// parseLevel falls back to info on unknown levels
+func parseLevel(level string) logrus.Level {
+	switch level {
 	case "error":
-		logrus.SetLevel(logrus.ErrorLevel)
+		return logrus.ErrorLevel
+	case "warn", "warning":
+		return logrus.WarnLevel
 	case "debug":
-		logrus.SetLevel(logrus.DebugLevel)
+		return logrus.DebugLevel
 	default:
-		logrus.SetLevel(logrus.InfoLevel)
+		return logrus.InfoLevel
 	}
+}
+
+// Init ...
+func Init(module string, appCfg *config.AppConfig) {
+	switch appCfg.Logging.Format {
+	case "json":
+		logrus.SetFormatter(&sampledFormatter{next: &logrus.JSONFormatter{
+			TimestampFormat: timeFormat,
+		}})
+	default:
+		customFormatter := &logrus.TextFormatter{}
+		customFormatter.TimestampFormat = timeFormat
+		customFormatter.FullTimestamp = true
+		logrus.SetFormatter(&sampledFormatter{next: customFormatter})
+	}
+	logrus.SetOutput(os.Stdout)
+	logrus.SetLevel(processLevel(module, appCfg))
 	logger = logrus.WithFields(logrus.Fields{
-		"module": module,
+		FieldModule: module,
 	})
+	rules = newRedactor(appCfg.Logging.Redact)
+	if len(appCfg.Logging.Sampling.Events) > 0 {
+		events = newSampler(appCfg.Logging.Sampling.Events)
+		interval := time.Duration(appCfg.Logging.Sampling.Interval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		go events.report(interval)
+	}
 	logger.WithFields(logrus.Fields{
-		"event": "init_logger",
+		FieldEvent: "init_logger",
+		"level":    logrus.GetLevel().String(),
 	}).Info("logger initiated")
 }
 
-// WithFields ...
+// Reload applies modules' log level from re-read config
+func Reload(module string, appCfg *config.AppConfig) {
+	logrus.SetLevel(processLevel(module, appCfg))
+}
+
+// SetLevel changes log level at runtime
+func SetLevel(level string) {
+	logrus.SetLevel(parseLevel(level))
+}
+
+// LevelHandler reports current log level on GET and changes it on PUT/POST with `level` query param,
+// e.g. `curl -X PUT localhost:2112/loglevel?level=debug`
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			level, err := logrus.ParseLevel(r.URL.Query().Get("level"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logrus.SetLevel(level)
+			logger.WithFields(logrus.Fields{
+				FieldEvent: "set_log_level",
+			}).Warn("log level changed to ", level)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"level": logrus.GetLevel().String()})
+	})
+}
+
+// WithFields applies redaction rules to a new record, it's sampled when written
 func WithFields(fields Fields) *logrus.Entry {
+	rules.redact(fields)
+	for _, key := range standardFields {
+		if value, ok := fields[key]; ok {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
 	return logger.WithFields(logrus.Fields(fields))
 }
 
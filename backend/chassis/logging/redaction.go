@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+const (
+	redacted = "[REDACTED]"
+)
+
+// redactedJSON - redacted value of JSON payloads
+var redactedJSON = json.RawMessage(`"` + redacted + `"`)
+
+// redactor masks configured keys in log fields and in payload/result/error maps passed as field values
+type redactor struct {
+	keys map[string]struct{}
+}
+
+func newRedactor(keys []string) *redactor {
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	return &redactor{keys: set}
+}
+
+func (r *redactor) masked(key string) bool {
+	_, ok := r.keys[key]
+	return ok
+}
+
+// redact replaces sensitive values in place, maps are copied so caller's data is untouched
+func (r *redactor) redact(fields Fields) {
+	if len(r.keys) == 0 {
+		return
+	}
+	for key, value := range fields {
+		if r.masked(key) {
+			fields[key] = redacted
+			continue
+		}
+		switch m := value.(type) {
+		case map[string]string:
+			clean := make(map[string]string, len(m))
+			for k, v := range m {
+				if r.masked(k) {
+					v = redacted
+				}
+				clean[k] = v
+			}
+			fields[key] = clean
+		case map[string]interface{}:
+			fields[key] = r.redactValue(m)
+		case protocol.Payload:
+			fields[key] = protocol.Payload(r.redactJSON(m))
+		case map[string]json.RawMessage:
+			fields[key] = r.redactJSON(m)
+		}
+	}
+}
+
+// redactJSON returns copy of JSON payload with masked values, nested objects and arrays included
+func (r *redactor) redactJSON(m map[string]json.RawMessage) map[string]json.RawMessage {
+	clean := make(map[string]json.RawMessage, len(m))
+	for k, v := range m {
+		if r.masked(k) {
+			v = redactedJSON
+		} else {
+			v = r.redactRaw(v)
+		}
+		clean[k] = v
+	}
+	return clean
+}
+
+// redactRaw masks keys of nested JSON objects, scalars are returned as is
+func (r *redactor) redactRaw(raw json.RawMessage) json.RawMessage {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return raw
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var value interface{}
+	if decoder.Decode(&value) != nil {
+		return raw
+	}
+	clean, err := json.Marshal(r.redactValue(value))
+	if err != nil {
+		return raw
+	}
+	return clean
+}
+
+// redactValue returns copy of decoded JSON value or field's map with masked keys at any depth
+func (r *redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clean := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if r.masked(key) {
+				clean[key] = redacted
+				continue
+			}
+			clean[key] = r.redactValue(item)
+		}
+		return clean
+	case []interface{}:
+		clean := make([]interface{}, len(v))
+		for i, item := range v {
+			clean[i] = r.redactValue(item)
+		}
+		return clean
+	default:
+		return value
+	}
+}
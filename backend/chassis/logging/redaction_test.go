@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		expect interface{}
+	}{
+		{
+			name:   "string map",
+			value:  map[string]string{"email": "a@b.c", "objectID": "23"},
+			expect: map[string]string{"email": redacted, "objectID": "23"},
+		},
+		{
+			name:   "interface map",
+			value:  map[string]interface{}{"email": "a@b.c", "objectID": 23},
+			expect: map[string]interface{}{"email": redacted, "objectID": 23},
+		},
+		{
+			name:   "payload",
+			value:  protocol.Payload{"email": json.RawMessage(`"a@b.c"`), "objectID": json.RawMessage(`23`)},
+			expect: protocol.Payload{"email": json.RawMessage(`"[REDACTED]"`), "objectID": json.RawMessage(`23`)},
+		},
+		{
+			name:   "raw JSON map",
+			value:  map[string]json.RawMessage{"email": json.RawMessage(`{"to": "a@b.c"}`), "objectID": json.RawMessage(`23`)},
+			expect: map[string]json.RawMessage{"email": json.RawMessage(`"[REDACTED]"`), "objectID": json.RawMessage(`23`)},
+		},
+		{
+			name: "nested payload",
+			value: protocol.Payload{
+				"user":  json.RawMessage(`{"email": "a@b.c", "id": 12345678901234567890}`),
+				"users": json.RawMessage(`[{"profile": {"email": "a@b.c"}}, 2]`),
+				"note":  json.RawMessage(`"{\"email\": \"text isn't parsed\"}"`),
+			},
+			expect: protocol.Payload{
+				"user":  json.RawMessage(`{"email":"[REDACTED]","id":12345678901234567890}`),
+				"users": json.RawMessage(`[{"profile":{"email":"[REDACTED]"}},2]`),
+				"note":  json.RawMessage(`"{\"email\": \"text isn't parsed\"}"`),
+			},
+		},
+		{
+			name:   "nested interface map",
+			value:  map[string]interface{}{"user": map[string]interface{}{"email": "a@b.c"}, "ids": []interface{}{1}},
+			expect: map[string]interface{}{"user": map[string]interface{}{"email": redacted}, "ids": []interface{}{1}},
+		},
+		{
+			name:   "plain value",
+			value:  "a@b.c",
+			expect: "a@b.c",
+		},
+	}
+	r := newRedactor([]string{"email", "token"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields := Fields{"params": test.value, "token": "secret"}
+			r.redact(fields)
+			if !reflect.DeepEqual(fields["params"], test.expect) {
+				t.Errorf("params = %v, want %v", fields["params"], test.expect)
+			}
+			if fields["token"] != redacted {
+				t.Errorf("token = %v, want %s", fields["token"], redacted)
+			}
+		})
+	}
+}
+
+func TestRedactKeepsCallersPayload(t *testing.T) {
+	payload := protocol.NewPayload(map[string]string{"email": "a@b.c"})
+	newRedactor([]string{"email"}).redact(Fields{"params": payload})
+	if payload.Get("email") != "a@b.c" {
+		t.Errorf("caller's payload is changed: %s", payload)
+	}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sampler limits how many records of high-frequency events are written per interval
+type sampler struct {
+	mu         sync.Mutex
+	limits     map[string]int
+	counts     map[string]int
+	suppressed map[string]int
+}
+
+func newSampler(limits map[string]int) *sampler {
+	return &sampler{
+		limits:     limits,
+		counts:     map[string]int{},
+		suppressed: map[string]int{},
+	}
+}
+
+// sampledFormatter drops records of events over their limit. Logrus formats only records of enabled
+// levels, so records filtered by level don't use up the limit.
+type sampledFormatter struct {
+	next logrus.Formatter
+}
+
+func (f *sampledFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if event, ok := entry.Data[FieldEvent].(string); ok && !events.allow(event) {
+		return nil, nil
+	}
+	return f.next.Format(entry)
+}
+
+// allow reports whether a record of event fits into current interval's limit
+func (s *sampler) allow(event string) bool {
+	limit, ok := s.limits[event]
+	if !ok {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts[event] < limit {
+		s.counts[event]++
+		return true
+	}
+	s.suppressed[event]++
+	return false
+}
+
+// reset starts a new interval and returns suppressed counts of the previous one
+func (s *sampler) reset() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	suppressed := s.suppressed
+	s.counts = map[string]int{}
+	s.suppressed = map[string]int{}
+	return suppressed
+}
+
+// report periodically logs how many records were suppressed
+func (s *sampler) report(interval time.Duration) {
+	for range time.Tick(interval) {
+		for event, count := range s.reset() {
+			logger.WithFields(logrus.Fields{
+				FieldEvent:   "suppressed_events",
+				"sampled":    event,
+				"suppressed": count,
+			}).Warn("suppressed ", count, " records of ", event)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSamplingSkipsDisabledLevels(t *testing.T) {
+	out := &bytes.Buffer{}
+	base := logrus.New()
+	base.SetOutput(out)
+	base.SetLevel(logrus.InfoLevel)
+	base.SetFormatter(&sampledFormatter{next: &logrus.TextFormatter{DisableTimestamp: true}})
+	savedLogger, savedEvents := logger, events
+	logger, events = logrus.NewEntry(base), newSampler(map[string]int{"noisy": 2})
+	defer func() {
+		logger, events = savedLogger, savedEvents
+	}()
+	tests := []struct {
+		level logrus.Level
+		count int
+	}{
+		{level: logrus.DebugLevel, count: 5},
+		{level: logrus.InfoLevel, count: 3},
+	}
+	for _, test := range tests {
+		for i := 0; i < test.count; i++ {
+			WithFields(Fields{"event": "noisy"}).Log(test.level, test.level.String())
+		}
+	}
+	WithFields(Fields{"event": "rare"}).Info("rare")
+	if got := strings.Count(out.String(), "msg=info"); got != 2 {
+		t.Errorf("info records = %d, want 2:\n%s", got, out)
+	}
+	if strings.Contains(out.String(), "msg=debug") || !strings.Contains(out.String(), "msg=rare") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if suppressed := events.reset(); suppressed["noisy"] != 1 {
+		t.Errorf("suppressed = %v, want 1 noisy record", suppressed)
+	}
+}
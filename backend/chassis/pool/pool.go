@@ -0,0 +1,51 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool - resizable set of worker goroutines
+type Pool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	group   *sync.WaitGroup
+	spawn   func(ctx context.Context, workerID int)
+	cancels []context.CancelFunc
+	// lastID - IDs aren't reused, a stopped worker may still finish its message after a new one is started
+	lastID int
+}
+
+// New creates an empty pool. spawn runs a worker until its ctx is canceled and must call group.Done on exit.
+func New(ctx context.Context, group *sync.WaitGroup, spawn func(ctx context.Context, workerID int)) *Pool {
+	return &Pool{
+		ctx:   ctx,
+		group: group,
+		spawn: spawn,
+	}
+}
+
+// Resize starts new workers or stops the most recent ones, so that n workers are running
+func (p *Pool) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.cancels) < n {
+		ctx, cancel := context.WithCancel(p.ctx)
+		p.cancels = append(p.cancels, cancel)
+		p.lastID++
+		p.group.Add(1)
+		go p.spawn(ctx, p.lastID)
+	}
+	for len(p.cancels) > n {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
+
+// Size ...
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.cancels)
+}
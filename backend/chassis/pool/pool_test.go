@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestResizeDoesNotReuseIDs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	group := &sync.WaitGroup{}
+	mu := sync.Mutex{}
+	started := []int{}
+	stopped := make(chan int, 10)
+	p := New(ctx, group, func(ctx context.Context, workerID int) {
+		mu.Lock()
+		started = append(started, workerID)
+		mu.Unlock()
+		<-ctx.Done()
+		stopped <- workerID
+		group.Done()
+	})
+	steps := []struct {
+		size    int
+		stopped []int
+	}{
+		{size: 3},
+		{size: 1, stopped: []int{2, 3}},
+		{size: 2},
+		{size: 0, stopped: []int{1, 4}},
+	}
+	for _, step := range steps {
+		p.Resize(step.size)
+		got := []int{}
+		for range step.stopped {
+			got = append(got, <-stopped)
+		}
+		sort.Ints(got)
+		if len(step.stopped) > 0 && !reflect.DeepEqual(got, step.stopped) {
+			t.Errorf("resize to %d stopped %v, want %v", step.size, got, step.stopped)
+		}
+		if p.Size() != step.size {
+			t.Errorf("size = %d, want %d", p.Size(), step.size)
+		}
+	}
+	group.Wait()
+	sort.Ints(started)
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(started, want) {
+		t.Errorf("started %v, want %v", started, want)
+	}
+}
@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Payload - JSON object of params, result or error, values keep their JSON types.
+// Messages of older producers carry strings only, they are read the same way.
+type Payload map[string]json.RawMessage
+
+// NewPayload converts string map, e.g. result of a handler, to payload
+func NewPayload(values map[string]string) Payload {
+	payload := make(Payload, len(values))
+	for key, value := range values {
+		payload.SetString(key, value)
+	}
+	return payload
+}
+
+// ToPayload converts struct or map, e.g. action's typed params, to payload
+func ToPayload(v interface{}) (Payload, error) {
+	if payload, ok := v.(Payload); ok {
+		return payload, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	payload := Payload{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Get returns string value unquoted and other values as JSON text, e.g. `23` or `{"id": 1}`
+func (p Payload) Get(key string) string {
+	raw, ok := p[key]
+	if !ok {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
+// String returns payload as JSON text
+func (p Payload) String() string {
+	bin, _ := json.Marshal(p)
+	return string(bin)
+}
+
+// Has reports whether payload has key
+func (p Payload) Has(key string) bool {
+	_, ok := p[key]
+	return ok
+}
+
+// SetString sets string value
+func (p Payload) SetString(key string, value string) {
+	raw, _ := json.Marshal(value)
+	p[key] = raw
+}
+
+// Set sets any JSON value
+func (p Payload) Set(key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	p[key] = raw
+	return nil
+}
+
+// Strings returns values as Get does, e.g. for logs and templates
+func (p Payload) Strings() map[string]string {
+	values := make(map[string]string, len(p))
+	for key := range p {
+		values[key] = p.Get(key)
+	}
+	return values
+}
+
+// Without returns copy of payload without keys
+func (p Payload) Without(keys ...string) Payload {
+	copied := make(Payload, len(p))
+	for key, value := range p {
+		copied[key] = value
+	}
+	for _, key := range keys {
+		delete(copied, key)
+	}
+	return copied
+}
+
+// Decode unmarshals payload into struct, e.g. action's typed params.
+// String values of older messages are accepted by numeric and boolean fields, e.g. "23" for int.
+func (p Payload) Decode(v interface{}) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(raw, v)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	// Retry with strings unquoted for numeric and boolean targets only
+	scalar := scalarKeys(reflect.TypeOf(v))
+	unquoted := make(Payload, len(p))
+	for key, value := range p {
+		unquoted[key] = value
+		if scalar != nil && !scalar[strings.ToLower(key)] {
+			continue
+		}
+		var text string
+		if json.Unmarshal(value, &text) != nil {
+			continue
+		}
+		if _, numErr := strconv.ParseFloat(text, 64); numErr == nil || text == "true" || text == "false" {
+			if json.Valid([]byte(text)) {
+				unquoted[key] = json.RawMessage(text)
+			}
+		}
+	}
+	raw, marshalErr := json.Marshal(unquoted)
+	if marshalErr != nil || json.Unmarshal(raw, v) != nil {
+		return err
+	}
+	return nil
+}
+
+// scalarKeys returns lowercased JSON keys of struct's numeric and boolean fields,
+// nil if target isn't a struct, e.g. map[string]int, and every key may be unquoted
+func scalarKeys(t reflect.Type) map[string]bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	keys := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			for key := range scalarKeys(field.Type) {
+				keys[key] = true
+			}
+			continue
+		}
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Ptr {
+			kind = field.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			keys[strings.ToLower(name)] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,166 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadGet(t *testing.T) {
+	payload := Payload{}
+	if err := json.Unmarshal([]byte(`{"legacy":"23","number":23,"flag":true,"list":[1,2],"object":{"id":1},"null":null}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "legacy", want: "23"},
+		{key: "number", want: "23"},
+		{key: "flag", want: "true"},
+		{key: "list", want: "[1,2]"},
+		{key: "object", want: `{"id":1}`},
+		{key: "null", want: ""},
+		{key: "missing", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if got := payload.Get(test.key); got != test.want {
+				t.Errorf("Get(%q) = %q, want %q", test.key, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPayloadDecode(t *testing.T) {
+	type params struct {
+		Count int      `json:"count"`
+		Ratio float64  `json:"ratio"`
+		Force bool     `json:"force"`
+		Name  string   `json:"name"`
+		IDs   []string `json:"ids"`
+	}
+	tests := []struct {
+		name    string
+		payload string
+		want    params
+		wantErr bool
+	}{
+		{
+			name:    "typed",
+			payload: `{"count":23,"ratio":0.5,"force":true,"name":"a","ids":["1"]}`,
+			want:    params{Count: 23, Ratio: 0.5, Force: true, Name: "a", IDs: []string{"1"}},
+		},
+		{
+			name:    "legacy strings",
+			payload: `{"count":"23","ratio":"0.5","force":"true","name":"a"}`,
+			want:    params{Count: 23, Ratio: 0.5, Force: true, Name: "a"},
+		},
+		{
+			name:    "legacy digits of string field",
+			payload: `{"count":"23","name":"42"}`,
+			want:    params{Count: 23, Name: "42"},
+		},
+		{
+			name:    "not a number",
+			payload: `{"count":"many"}`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := Payload{}
+			if err := json.Unmarshal([]byte(test.payload), &payload); err != nil {
+				t.Fatal(err)
+			}
+			got := params{}
+			err := payload.Decode(&got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPayloadDecodeUntagged(t *testing.T) {
+	payload := NewPayload(map[string]string{"objectID": "23", "name": "42"})
+	got := struct {
+		ObjectID int
+		Name     string
+	}{}
+	if err := payload.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ObjectID != 23 || got.Name != "42" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestToPayload(t *testing.T) {
+	payload, err := ToPayload(struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}{ID: 1, Items: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.String() != `{"id":1,"items":["a"]}` {
+		t.Errorf("payload = %s", payload)
+	}
+	if _, err := ToPayload([]int{1}); err == nil {
+		t.Error("array converted to payload, want error")
+	}
+}
+
+func TestFollowUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     Payload
+	}{
+		{
+			name:     "object",
+			response: `{"jsonrpc":"2.0","id":"1","result":{"followUp":{"ids":[1,2]}}}`,
+			want:     Payload{"ids": json.RawMessage(`[1,2]`)},
+		},
+		{
+			name:     "legacy string",
+			response: `{"jsonrpc":"2.0","id":"1","result":{"followUp":"{\"ids\":\"1,2\"}"}}`,
+			want:     NewPayload(map[string]string{"ids": "1,2"}),
+		},
+		{
+			name:     "none",
+			response: `{"jsonrpc":"2.0","id":"1","result":{"sum":"2"}}`,
+		},
+		{
+			name:     "ignored with error",
+			response: `{"jsonrpc":"2.0","id":"1","result":{"followUp":{"ids":[1]}},"error":{"code":"1"}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := Response{}
+			if err := response.FromJSON(test.response); err != nil {
+				t.Fatal(err)
+			}
+			got, err := response.FollowUp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+	response := &Response{}
+	if err := response.SetFollowUp(NewPayload(map[string]string{"ids": "3"})); err != nil {
+		t.Fatal(err)
+	}
+	got, err := response.FollowUp()
+	if err != nil || got.Get("ids") != "3" {
+		t.Errorf("got %s, %v, want ids 3", got, err)
+	}
+}
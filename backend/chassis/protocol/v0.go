@@ -1,26 +1,69 @@
 package protocol
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 )
 
-// Request - JSON-RPC request packet
+// Request - submit of a producer or task's attempt sent to workers.
+// Its wire format is chosen by Version, see v0Request and v1Request.
 type Request struct {
-	Protocol string            `json:"jsonrpc"`
-	ID       string            `json:"id,omitempty"`
-	Method   string            `json:"method"`
-	Params   map[string]string `json:"params"`
+	// Version of JSON(), FromJSON sets version of received message
+	Version        int
+	ID             string
+	Method         string
+	Params         Payload
+	IdempotencyKey string
+	Trace          map[string]string
+	// Callback of submit receives task's final result
+	Callback *Callback
+	// ReplyTo - shorthand of callback's queue, reply's id is submit's id
+	ReplyTo string
+	// Priority of submitted task, higher is acquired first
+	Priority int
+	// Delay - seconds before the first attempt of submitted task
+	Delay int
+	// TaskID, Attempt and Deadline of attempts sent to workers, v0 carries attempt only
+	TaskID   string
+	Attempt  int
+	Deadline time.Time
+	// ContentType of params, ContentJSON if empty
+	ContentType string
+
+	ctx context.Context
+}
+
+// Callback - URL or reply queue which receives JSON-RPC response of finished task
+type Callback struct {
+	URL   string `json:"url,omitempty"`
+	Queue string `json:"queue,omitempty"`
+}
+
+// Context returns request's context, it's never nil
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
 }
 
-// JSON - convert struct to json
+// WithContext returns shallow copy of request with ctx, like http.Request.WithContext
+func (r *Request) WithContext(ctx context.Context) *Request {
+	copied := *r
+	copied.ctx = ctx
+	return &copied
+}
+
+// JSON - convert struct to json of request's version
 func (r *Request) JSON() (string, error) {
-	r.Protocol = "2.0"
 	bin, err := json.Marshal(r)
 	return string(bin), err
 }
 
-// FromJSON - convert json to struct
+// FromJSON - convert json of any supported version to struct
 func (r *Request) FromJSON(jsonString string) error {
 	jsonBytes := []byte(jsonString)
 	return json.Unmarshal(jsonBytes, r)
@@ -28,25 +71,32 @@ func (r *Request) FromJSON(jsonString string) error {
 
 // String representation
 func (r *Request) String() string {
-	return fmt.Sprintf("id=%s method=%s params=%s", r.ID, r.Method, r.Params)
+	return fmt.Sprintf("id=%s method=%s attempt=%d params=%s", r.ID, r.Method, r.Attempt, r.Params)
 }
 
-// Response - JSON-RPC response packet
+// Response - result of task's attempt or reply to submit.
+// Its wire format is chosen by Version, see v0Response and v1Response.
 type Response struct {
-	Protocol string            `json:"jsonrpc"`
-	ID       string            `json:"id"`
-	Result   map[string]string `json:"result,omitempty"`
-	Error    map[string]string `json:"error,omitempty"`
+	// Version of JSON(), FromJSON sets version of received message
+	Version int
+	ID      string
+	TaskID  string
+	State   string // Replies to submits only, SUCCESS, CRITICAL_ERROR or CANCELED
+	Attempt int
+	Result  Payload
+	Error   Payload
+	Trace   map[string]string
+	// ContentType of result and error, ContentJSON if empty
+	ContentType string
 }
 
-// JSON - convert struct to json
+// JSON - convert struct to json of response's version
 func (r *Response) JSON() (string, error) {
-	r.Protocol = "2.0"
 	bin, err := json.Marshal(r)
 	return string(bin), err
 }
 
-// FromJSON - convert json to struct
+// FromJSON - convert json of any supported version to struct
 func (r *Response) FromJSON(jsonString string) error {
 	jsonBytes := []byte(jsonString)
 	return json.Unmarshal(jsonBytes, r)
@@ -54,5 +104,239 @@ func (r *Response) FromJSON(jsonString string) error {
 
 // String representation
 func (r *Response) String() string {
-	return fmt.Sprintf("id=%s result=%s error=%s", r.ID, r.Result, r.Error)
+	return fmt.Sprintf("id=%s attempt=%d result=%s error=%s", r.ID, r.Attempt, r.Result, r.Error)
+}
+
+// v0Request - JSON-RPC 2.0 request, attempt is a string in params
+type v0Request struct {
+	Protocol       string            `json:"jsonrpc"`
+	ID             string            `json:"id,omitempty"`
+	Method         string            `json:"method"`
+	Params         Payload           `json:"params"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	Trace          map[string]string `json:"trace,omitempty"`
+	Callback       *Callback         `json:"callback,omitempty"`
+	ReplyTo        string            `json:"replyTo,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	Delay          int               `json:"delay,omitempty"`
+}
+
+func (r *Request) toV0() *v0Request {
+	params := r.Params
+	if r.Attempt > 0 {
+		params = params.Without()
+		params.SetString(attemptKey, strconv.Itoa(r.Attempt))
+	}
+	return &v0Request{
+		Protocol:       "2.0",
+		ID:             r.ID,
+		Method:         r.Method,
+		Params:         params,
+		IdempotencyKey: r.IdempotencyKey,
+		Trace:          r.Trace,
+		Callback:       r.Callback,
+		ReplyTo:        r.ReplyTo,
+		Priority:       r.Priority,
+		Delay:          r.Delay,
+	}
+}
+
+func (r *Request) fromV0(message *v0Request) {
+	*r = Request{
+		Version:        V0,
+		ID:             message.ID,
+		Method:         message.Method,
+		Params:         message.Params,
+		IdempotencyKey: message.IdempotencyKey,
+		Trace:          message.Trace,
+		Callback:       message.Callback,
+		ReplyTo:        message.ReplyTo,
+		Priority:       message.Priority,
+		Delay:          message.Delay,
+		ctx:            r.ctx,
+	}
+	if r.Params.Has(attemptKey) {
+		r.Attempt, _ = strconv.Atoi(r.Params.Get(attemptKey))
+		r.Params = r.Params.Without(attemptKey)
+	}
+}
+
+// v0Response - JSON-RPC 2.0 response, attempt is a string in error or, if there is none, in result
+type v0Response struct {
+	Protocol string            `json:"jsonrpc"`
+	ID       string            `json:"id"`
+	TaskID   string            `json:"taskID,omitempty"`
+	State    string            `json:"state,omitempty"`
+	Result   Payload           `json:"result,omitempty"`
+	Error    Payload           `json:"error,omitempty"`
+	Trace    map[string]string `json:"trace,omitempty"`
+}
+
+func (r *Response) toV0() *v0Response {
+	message := &v0Response{
+		Protocol: "2.0",
+		ID:       r.ID,
+		TaskID:   r.TaskID,
+		State:    r.State,
+		Result:   r.Result,
+		Error:    r.Error,
+		Trace:    r.Trace,
+	}
+	if r.Attempt > 0 {
+		if len(r.Error) > 0 {
+			message.Error = r.Error.Without()
+			message.Error.SetString(attemptKey, strconv.Itoa(r.Attempt))
+		} else {
+			message.Result = r.Result.Without()
+			message.Result.SetString(attemptKey, strconv.Itoa(r.Attempt))
+		}
+	}
+	return message
+}
+
+func (r *Response) fromV0(message *v0Response) {
+	*r = Response{
+		Version: V0,
+		ID:      message.ID,
+		TaskID:  message.TaskID,
+		State:   message.State,
+		Result:  message.Result,
+		Error:   message.Error,
+		Trace:   message.Trace,
+	}
+	attempt := r.Result
+	if len(r.Error) > 0 {
+		attempt = r.Error
+	}
+	if attempt.Has(attemptKey) {
+		r.Attempt, _ = strconv.Atoi(attempt.Get(attemptKey))
+	}
+	if r.Result.Has(attemptKey) {
+		r.Result = r.Result.Without(attemptKey)
+	}
+	if r.Error.Has(attemptKey) {
+		r.Error = r.Error.Without(attemptKey)
+	}
+}
+
+// Task - task's state returned by HTTP API
+type Task struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	State     string    `json:"state"`
+	Params    Payload   `json:"params"`
+	Result    Payload   `json:"result,omitempty"`
+	Error     Payload   `json:"error,omitempty"`
+	Attempts  int       `json:"attempts"`
+	Priority  int       `json:"priority"`
+	CreatedDt time.Time `json:"createdDt"`
+	UpdatedDt time.Time `json:"updatedDt"`
+}
+
+// Error codes of Response.Error
+const (
+	// CodeInternal - JSON-RPC code, handler panicked
+	CodeInternal = "-32603"
+	// CodeTimeout - handler didn't finish within action's timeout
+	CodeTimeout = "-32000"
+	// CodeMethodNotFound - JSON-RPC code, worker has no handler of task's action
+	CodeMethodNotFound = "-32601"
+)
+
+const (
+	// attemptKey - attempt's number in v0 params, result and error
+	attemptKey = "attempt"
+	// permanentKey marks response's error that must not be retried
+	permanentKey = "permanent"
+	// followUpKey - JSON params of a follow-up task in response's result
+	followUpKey = "followUp"
+)
+
+// NewError returns response with error of request's attempt, permanent error moves task to CRITICAL_ERROR at once
+func NewError(request *Request, code string, message string, permanent bool) *Response {
+	response := &Response{
+		ID:      request.ID,
+		TaskID:  request.TaskID,
+		Attempt: request.Attempt,
+		Error: NewPayload(map[string]string{
+			"code":    code,
+			"message": message,
+		}),
+	}
+	if permanent {
+		response.Error.SetString(permanentKey, "true")
+	}
+	return response
+}
+
+// NewResult returns successful response of request's attempt
+func NewResult(request *Request, result map[string]string) *Response {
+	return &Response{
+		ID:      request.ID,
+		TaskID:  request.TaskID,
+		Attempt: request.Attempt,
+		Result:  NewPayload(result),
+	}
+}
+
+// NewJSONResult returns successful response with typed result, it must be encoded as JSON object
+func NewJSONResult(request *Request, result interface{}) (*Response, error) {
+	payload, err := ToPayload(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		ID:      request.ID,
+		TaskID:  request.TaskID,
+		Attempt: request.Attempt,
+		Result:  payload,
+	}, nil
+}
+
+// Decode unmarshals request's params into action's typed params
+func (r *Request) Decode(v interface{}) error {
+	return r.Params.Decode(v)
+}
+
+// SetPermanent marks response's error as permanent or retryable
+func (r *Response) SetPermanent(permanent bool) {
+	if len(r.Error) == 0 {
+		return
+	}
+	if permanent {
+		r.Error.SetString(permanentKey, "true")
+	} else {
+		delete(r.Error, permanentKey)
+	}
+}
+
+// Permanent reports whether response's error must not be retried
+func (r *Response) Permanent() bool {
+	return r.Error.Get(permanentKey) == "true"
+}
+
+// SetFollowUp asks resulter to enqueue a new task of the same action with params, e.g. failed items of a batch
+func (r *Response) SetFollowUp(params Payload) error {
+	if r.Result == nil {
+		r.Result = Payload{}
+	}
+	return r.Result.Set(followUpKey, params)
+}
+
+// FollowUp returns params of a follow-up task, nil if there is none.
+// Older workers send them as JSON text in a string.
+func (r *Response) FollowUp() (Payload, error) {
+	raw, ok := r.Result[followUpKey]
+	if !ok || len(r.Error) > 0 {
+		return nil, nil
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		raw = json.RawMessage(text)
+	}
+	params := Payload{}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
 }
@@ -0,0 +1,235 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Versions of wire format, consumers accept all of them and producers emit the configured one
+const (
+	// V0 - JSON-RPC 2.0 message, attempt is carried in params, result or error
+	V0 = 0
+	// V1 - envelope with explicit version and metadata: task ID, attempt, deadline, trace and content type
+	V1 = 1
+)
+
+// ContentJSON - content type of params, result and error, the only supported one
+const ContentJSON = "application/json"
+
+var (
+	// ErrUnsupportedVersion - message's version is unknown to consumer, e.g. it's newer
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	// ErrUnsupportedContentType - message's content type can't be decoded
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+)
+
+// v1Request - versioned request, metadata is out of params
+type v1Request struct {
+	Version        int               `json:"version"`
+	ID             string            `json:"id,omitempty"`
+	Method         string            `json:"method"`
+	TaskID         string            `json:"taskID,omitempty"`
+	Attempt        int               `json:"attempt,omitempty"`
+	Deadline       *time.Time        `json:"deadline,omitempty"`
+	Trace          map[string]string `json:"trace,omitempty"`
+	ContentType    string            `json:"contentType"`
+	Params         Payload           `json:"params"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	Callback       *Callback         `json:"callback,omitempty"`
+	ReplyTo        string            `json:"replyTo,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	Delay          int               `json:"delay,omitempty"`
+}
+
+func (r *Request) toV1() *v1Request {
+	message := &v1Request{
+		Version:        V1,
+		ID:             r.ID,
+		Method:         r.Method,
+		TaskID:         r.TaskID,
+		Attempt:        r.Attempt,
+		Trace:          r.Trace,
+		ContentType:    contentType(r.ContentType),
+		Params:         r.Params,
+		IdempotencyKey: r.IdempotencyKey,
+		Callback:       r.Callback,
+		ReplyTo:        r.ReplyTo,
+		Priority:       r.Priority,
+		Delay:          r.Delay,
+	}
+	if !r.Deadline.IsZero() {
+		deadline := r.Deadline.UTC()
+		message.Deadline = &deadline
+	}
+	return message
+}
+
+func (r *Request) fromV1(message *v1Request) {
+	*r = Request{
+		Version:        V1,
+		ID:             message.ID,
+		Method:         message.Method,
+		Params:         message.Params,
+		IdempotencyKey: message.IdempotencyKey,
+		Trace:          message.Trace,
+		Callback:       message.Callback,
+		ReplyTo:        message.ReplyTo,
+		Priority:       message.Priority,
+		Delay:          message.Delay,
+		TaskID:         message.TaskID,
+		Attempt:        message.Attempt,
+		ContentType:    message.ContentType,
+		ctx:            r.ctx,
+	}
+	if message.Deadline != nil {
+		r.Deadline = *message.Deadline
+	}
+}
+
+// v1Response - versioned response, metadata is out of result and error
+type v1Response struct {
+	Version     int               `json:"version"`
+	ID          string            `json:"id"`
+	TaskID      string            `json:"taskID,omitempty"`
+	State       string            `json:"state,omitempty"`
+	Attempt     int               `json:"attempt,omitempty"`
+	Trace       map[string]string `json:"trace,omitempty"`
+	ContentType string            `json:"contentType"`
+	Result      Payload           `json:"result,omitempty"`
+	Error       Payload           `json:"error,omitempty"`
+}
+
+func (r *Response) toV1() *v1Response {
+	return &v1Response{
+		Version:     V1,
+		ID:          r.ID,
+		TaskID:      r.TaskID,
+		State:       r.State,
+		Attempt:     r.Attempt,
+		Trace:       r.Trace,
+		ContentType: contentType(r.ContentType),
+		Result:      r.Result,
+		Error:       r.Error,
+	}
+}
+
+func (r *Response) fromV1(message *v1Response) {
+	*r = Response{
+		Version:     V1,
+		ID:          message.ID,
+		TaskID:      message.TaskID,
+		State:       message.State,
+		Attempt:     message.Attempt,
+		Result:      message.Result,
+		Error:       message.Error,
+		Trace:       message.Trace,
+		ContentType: message.ContentType,
+	}
+}
+
+func contentType(value string) string {
+	if value == "" {
+		return ContentJSON
+	}
+	return value
+}
+
+// version returns version of message, v0 messages don't have it
+func version(data []byte) (int, error) {
+	header := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	switch header.Version {
+	case V0, V1:
+		return header.Version, nil
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+}
+
+// checkContentType rejects payloads which aren't JSON
+func checkContentType(value string) error {
+	if contentType(value) != ContentJSON {
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, value)
+	}
+	return nil
+}
+
+// MarshalJSON encodes request in format of its Version
+func (r Request) MarshalJSON() ([]byte, error) {
+	switch r.Version {
+	case V0:
+		return json.Marshal(r.toV0())
+	case V1:
+		return json.Marshal(r.toV1())
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, r.Version)
+	}
+}
+
+// UnmarshalJSON decodes request of any supported version
+func (r *Request) UnmarshalJSON(data []byte) error {
+	v, err := version(data)
+	if err != nil {
+		return err
+	}
+	if v == V0 {
+		message := &v0Request{}
+		if err := json.Unmarshal(data, message); err != nil {
+			return err
+		}
+		r.fromV0(message)
+		return nil
+	}
+	message := &v1Request{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return err
+	}
+	if err := checkContentType(message.ContentType); err != nil {
+		return err
+	}
+	r.fromV1(message)
+	return nil
+}
+
+// MarshalJSON encodes response in format of its Version
+func (r Response) MarshalJSON() ([]byte, error) {
+	switch r.Version {
+	case V0:
+		return json.Marshal(r.toV0())
+	case V1:
+		return json.Marshal(r.toV1())
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, r.Version)
+	}
+}
+
+// UnmarshalJSON decodes response of any supported version
+func (r *Response) UnmarshalJSON(data []byte) error {
+	v, err := version(data)
+	if err != nil {
+		return err
+	}
+	if v == V0 {
+		message := &v0Response{}
+		if err := json.Unmarshal(data, message); err != nil {
+			return err
+		}
+		r.fromV0(message)
+		return nil
+	}
+	message := &v1Response{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return err
+	}
+	if err := checkContentType(message.ContentType); err != nil {
+		return err
+	}
+	r.fromV1(message)
+	return nil
+}
@@ -0,0 +1,194 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	deadline := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		request Request
+		want    Request
+	}{
+		{
+			name: "v0",
+			request: Request{
+				Version: V0, ID: "1", Method: "sum", Attempt: 2,
+				Params: NewPayload(map[string]string{"a": "1"}),
+			},
+			want: Request{
+				Version: V0, ID: "1", Method: "sum", Attempt: 2,
+				Params: NewPayload(map[string]string{"a": "1"}),
+			},
+		},
+		{
+			name: "v0 drops metadata it can't carry",
+			request: Request{
+				Version: V0, ID: "1", Method: "sum", TaskID: "7", Deadline: deadline,
+				Params: NewPayload(map[string]string{"a": "1"}),
+			},
+			want: Request{
+				Version: V0, ID: "1", Method: "sum",
+				Params: NewPayload(map[string]string{"a": "1"}),
+			},
+		},
+		{
+			name: "v1",
+			request: Request{
+				Version: V1, ID: "1", Method: "sum", TaskID: "7", Attempt: 2, Deadline: deadline,
+				Trace:  map[string]string{"traceparent": "00-1"},
+				Params: NewPayload(map[string]string{"a": "1", "attempt": "user's"}),
+			},
+			want: Request{
+				Version: V1, ID: "1", Method: "sum", TaskID: "7", Attempt: 2, Deadline: deadline,
+				Trace:       map[string]string{"traceparent": "00-1"},
+				ContentType: ContentJSON,
+				Params:      NewPayload(map[string]string{"a": "1", "attempt": "user's"}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, err := test.request.JSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Request{}
+			if err := got.FromJSON(text); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		response Response
+	}{
+		{
+			name: "v0 result",
+			response: Response{
+				Version: V0, ID: "1", TaskID: "7", Attempt: 3,
+				Result: NewPayload(map[string]string{"sum": "2"}),
+			},
+		},
+		{
+			name: "v0 error",
+			response: Response{
+				Version: V0, ID: "1", Attempt: 3,
+				Result: NewPayload(map[string]string{"partial": "true"}),
+				Error:  NewPayload(map[string]string{"code": "1"}),
+			},
+		},
+		{
+			name: "v1",
+			response: Response{
+				Version: V1, ID: "1", TaskID: "7", Attempt: 3, State: "SUCCESS", ContentType: ContentJSON,
+				Result: NewPayload(map[string]string{"sum": "2"}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, err := test.response.JSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Response{}
+			if err := got.FromJSON(text); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.response) {
+				t.Errorf("got %+v, want %+v", got, test.response)
+			}
+		})
+	}
+}
+
+func TestAttemptOfV0(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		attempt int
+		result  Payload
+		error   Payload
+	}{
+		{
+			name:    "in result",
+			message: `{"jsonrpc":"2.0","id":"1","result":{"sum":"2","attempt":"2"}}`,
+			attempt: 2,
+			result:  NewPayload(map[string]string{"sum": "2"}),
+		},
+		{
+			name:    "in error",
+			message: `{"jsonrpc":"2.0","id":"1","result":{"attempt":"5"},"error":{"code":"1","attempt":"3"}}`,
+			attempt: 3,
+			result:  Payload{},
+			error:   NewPayload(map[string]string{"code": "1"}),
+		},
+		{
+			name:    "missing",
+			message: `{"jsonrpc":"2.0","id":"1","result":{"sum":"2"}}`,
+			result:  NewPayload(map[string]string{"sum": "2"}),
+		},
+		{
+			name:    "not a number",
+			message: `{"jsonrpc":"2.0","id":"1","result":{"attempt":"first"}}`,
+			result:  Payload{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := Response{}
+			if err := response.FromJSON(test.message); err != nil {
+				t.Fatal(err)
+			}
+			if response.Version != V0 || response.Attempt != test.attempt {
+				t.Errorf("version = %d, attempt = %d, want 0 and %d", response.Version, response.Attempt, test.attempt)
+			}
+			if !reflect.DeepEqual(response.Result, test.result) || !reflect.DeepEqual(response.Error, test.error) {
+				t.Errorf("result = %s, error = %s, want %s and %s", response.Result, response.Error, test.result, test.error)
+			}
+		})
+	}
+	request := Request{}
+	if err := request.FromJSON(`{"jsonrpc":"2.0","id":"1","method":"sum","params":{"a":"1","attempt":"4"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if request.Attempt != 4 || request.Params.Has(attemptKey) {
+		t.Errorf("attempt = %d, params = %s, want 4 out of params", request.Attempt, request.Params)
+	}
+}
+
+func TestUnsupportedMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		err     error
+	}{
+		{name: "newer version", message: `{"version":2,"id":"1","method":"sum","params":{}}`, err: ErrUnsupportedVersion},
+		{name: "content type", message: `{"version":1,"id":"1","method":"sum","contentType":"text/plain","params":{}}`, err: ErrUnsupportedContentType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := (&Request{}).FromJSON(test.message); !errors.Is(err, test.err) {
+				t.Errorf("request error = %v, want %v", err, test.err)
+			}
+			if err := (&Response{}).FromJSON(test.message); !errors.Is(err, test.err) {
+				t.Errorf("response error = %v, want %v", err, test.err)
+			}
+		})
+	}
+	if _, err := json.Marshal(Request{Version: 2}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("marshal error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
@@ -1,11 +1,11 @@
 package queue
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -16,6 +16,7 @@ import (
 // AWSQueue implementation
 type AWSQueue struct {
 	QueueURL string
+	name     string
 	queue    *sqs.SQS
 }
 
@@ -30,6 +31,7 @@ func InitAWSQueue(cfg Config) Client {
 	URL := fmt.Sprintf("%s/%s", cfg.URL, cfg.Name)
 	return &AWSQueue{
 		queue:    queue,
+		name:     cfg.Name,
 		QueueURL: URL,
 	}
 }
@@ -42,9 +44,11 @@ func (q AWSQueue) SendMessage(message string) error {
 		QueueUrl:     aws.String(q.QueueURL), // Required
 		DelaySeconds: aws.Int64(0),           // (optional) 0s - 900s (15 minutes)
 	}
+	start := time.Now()
 	sendResponse, err := q.queue.SendMessage(msg)
+	observe(q.name, "send", start, err)
 	if err != nil {
-		return err
+		return &OpError{Op: "send", Queue: q.QueueURL, Err: err}
 	}
 	log.WithFields(log.Fields{
 		"event": "send_message",
@@ -62,12 +66,14 @@ func (q AWSQueue) ReceiveMessage() (*RecvMessage, error) {
 		MaxNumberOfMessages: aws.Int64(1),
 		WaitTimeSeconds:     aws.Int64(5),
 	}
+	start := time.Now()
 	receiveResponse, err := q.queue.ReceiveMessage(receivedMsg)
+	observe(q.name, "receive", start, err)
 	if err != nil {
-		return nil, err
+		return nil, &OpError{Op: "receive", Queue: q.QueueURL, Err: err}
 	}
 	if len(receiveResponse.Messages) == 0 {
-		return nil, errors.New("no message received")
+		return nil, ErrNoMessage
 	}
 	msg := &RecvMessage{
 		ID:      *receiveResponse.Messages[0].MessageId,
@@ -87,10 +93,12 @@ func (q AWSQueue) Acknowledge(message *RecvMessage) error {
 		QueueUrl:      aws.String(q.QueueURL),
 		ReceiptHandle: &message.Handler,
 	}
+	start := time.Now()
 	_, err := q.queue.DeleteMessage(deleteMsg)
+	observe(q.name, "acknowledge", start, err)
 	time.Sleep(time.Second)
 	if err != nil {
-		return err
+		return &OpError{Op: "acknowledge", Queue: q.QueueURL, Err: err}
 	}
 	log.WithFields(log.Fields{
 		"event": "delete_message",
@@ -98,3 +106,16 @@ func (q AWSQueue) Acknowledge(message *RecvMessage) error {
 	}).Debug(message.ID)
 	return nil
 }
+
+// Ping checks that queue exists and is reachable
+func (q AWSQueue) Ping(ctx context.Context) error {
+	attrs := &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(q.QueueURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	}
+	_, err := q.queue.GetQueueAttributesWithContext(ctx, attrs)
+	if err != nil {
+		return &OpError{Op: "ping", Queue: q.QueueURL, Err: err}
+	}
+	return nil
+}
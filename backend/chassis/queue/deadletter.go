@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+)
+
+// ErrNoDeadLetter - dead-letter queue is not configured, message must stay in its queue
+var ErrNoDeadLetter = errors.New("dead-letter queue is not configured")
+
+// DeadLetter - message which can't be processed, it's parked in dead-letter queue with the reason
+type DeadLetter struct {
+	Module string    `json:"module"`
+	Reason string    `json:"reason"`
+	Error  string    `json:"error"`
+	Body   string    `json:"body"`
+	Dt     time.Time `json:"dt"`
+}
+
+// SendDeadLetter sends message to dead-letter queue. Nil client means that dead-letter queue is
+// not configured: message is logged and ErrNoDeadLetter is returned, so caller doesn't acknowledge it.
+func SendDeadLetter(cli Client, module string, reason string, msg *RecvMessage, err error) error {
+	if cli == nil {
+		fields := log.Fields{
+			"event":  "dead_letter_unavailable",
+			"module": module,
+			"reason": reason,
+			"body":   msg.Body,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		log.WithFields(fields).Error(ErrNoDeadLetter)
+		return ErrNoDeadLetter
+	}
+	letter := DeadLetter{
+		Module: module,
+		Reason: reason,
+		Body:   msg.Body,
+		Dt:     time.Now(),
+	}
+	if err != nil {
+		letter.Error = err.Error()
+	}
+	bin, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	if err := cli.SendMessage(string(bin)); err != nil {
+		return err
+	}
+	deadLetters.WithLabelValues(module, reason).Inc()
+	return nil
+}
@@ -0,0 +1,25 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoMessage - queue had no message during receive
+var ErrNoMessage = errors.New("no message received")
+
+// OpError - failed queue operation
+type OpError struct {
+	Op    string
+	Queue string
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Queue, e.Err)
+}
+
+// Unwrap ...
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
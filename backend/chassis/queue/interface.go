@@ -1,5 +1,11 @@
 package queue
 
+import (
+	"context"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+)
+
 // Config - unified configuration for queue service
 type Config struct {
 	Name string
@@ -12,6 +18,20 @@ type Config struct {
 	Retries            int
 }
 
+// NewConfig builds queue config from module's queue section and AWS credentials
+func NewConfig(q config.Queue, aws config.AWS) Config {
+	return Config{
+		Name:    q.Name,
+		URL:     q.URL,
+		Retries: q.Retries,
+
+		//AWS specific
+		Region:             aws.Region,
+		CredentialsFile:    aws.CredentialsFile,
+		CredentialsProfile: aws.CredentialsProfile,
+	}
+}
+
 // RecvMessage unified presentation for queue message
 type RecvMessage struct {
 	ID      string
@@ -24,4 +44,5 @@ type Client interface {
 	SendMessage(message string) error
 	ReceiveMessage() (*RecvMessage, error)
 	Acknowledge(*RecvMessage) error
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "scheduler",
+	Subsystem: "queue",
+	Name:      "operation_duration_seconds",
+	Help:      "Queue receive/send/ack latency per queue.",
+	Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+}, []string{"queue", "operation", "status"})
+
+// observe records operation latency since start
+func observe(queue string, operation string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	operationDuration.WithLabelValues(queue, operation, status).Observe(time.Since(start).Seconds())
+}
+
+var deadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scheduler",
+	Subsystem: "queue",
+	Name:      "dead_letters_total",
+	Help:      "Messages parked in dead-letter queue by module and reason.",
+}, []string{"module", "reason"})
@@ -0,0 +1,12 @@
+package recovery
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var panics = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scheduler",
+	Name:      "panics_total",
+	Help:      "Panics recovered in worker loops per module.",
+}, []string{"module"})
@@ -0,0 +1,107 @@
+package recovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+	"github.com/freundallein/scheduler/backend/chassis/queue"
+)
+
+// Panic - recovered panic, Digest identifies its stack regardless of goroutine and arguments
+type Panic struct {
+	Value  interface{}
+	Stack  string
+	Digest string
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v [%s]", p.Value, p.Digest)
+}
+
+var (
+	mu         sync.Mutex
+	maxStrikes = 3
+	strikes    = map[string]int{}
+)
+
+// Init sets how many panics of the same message are tolerated before it's moved to dead-letter queue
+func Init(appCfg *config.AppConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	maxStrikes = appCfg.Recovery.MaxStrikes
+}
+
+// digest hashes functions and lines of panicking stack
+func digest() string {
+	pcs := make([]uintptr, 32)
+	// Skip runtime.Callers, digest, deferred func and runtime.gopanic
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	hash := sha256.New()
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(hash, "%s:%d\n", frame.Function, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// Do runs fn, its panic is logged, counted and returned as *Panic
+func Do(module string, fn func()) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			p := &Panic{
+				Value:  recovered,
+				Stack:  string(debug.Stack()),
+				Digest: digest(),
+			}
+			panics.WithLabelValues(module).Inc()
+			log.WithFields(log.Fields{
+				"event":  "panic_recovered",
+				"digest": p.Digest,
+				"stack":  p.Stack,
+			}).Error(p)
+			err = p
+		}
+	}()
+	fn()
+	return nil
+}
+
+// Message runs handling of a queue message. Message which panicked is redelivered by the queue,
+// after Recovery.MaxStrikes panics it's moved to dead-letter queue and acknowledged.
+func Message(module string, cli queue.Client, deadLetter queue.Client, msg *queue.RecvMessage, fn func()) {
+	err := Do(module, fn)
+	mu.Lock()
+	if err == nil {
+		delete(strikes, msg.ID)
+		mu.Unlock()
+		return
+	}
+	strikes[msg.ID]++
+	exhausted := strikes[msg.ID] >= maxStrikes
+	if exhausted {
+		delete(strikes, msg.ID)
+	}
+	mu.Unlock()
+	if !exhausted {
+		return
+	}
+	err = queue.SendDeadLetter(deadLetter, module, "panic", msg, err)
+	if err == nil {
+		err = cli.Acknowledge(msg)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event": "dead_letter_failed",
+		}).Error(err)
+	}
+}
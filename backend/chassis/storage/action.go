@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SubmitPrefix - submit request's method is SubmitPrefix + action name, e.g. `submit:export`
+const SubmitPrefix = "submit:"
+
+var (
+	actionsMu sync.RWMutex
+	actions   = map[Action]bool{
+		DUMMY:  true,
+		EXPORT: true,
+	}
+)
+
+// RegisterAction adds action to the catalog shared by all roles of the process.
+// Names are case-insensitive, `resize_image` is stored as RESIZE_IMAGE.
+func RegisterAction(name string) Action {
+	action := Action(strings.ToUpper(strings.TrimSpace(name)))
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+	actions[action] = true
+	return action
+}
+
+// ParseAction returns registered action by name
+func ParseAction(name string) (Action, error) {
+	action := Action(strings.ToUpper(strings.TrimSpace(name)))
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	if !actions[action] {
+		return "", fmt.Errorf("%w: %q", ErrUnknownAction, name)
+	}
+	return action, nil
+}
+
+// ParseSubmit returns registered action of `submit:<action>` method
+func ParseSubmit(method string) (Action, error) {
+	if !strings.HasPrefix(method, SubmitPrefix) {
+		return "", fmt.Errorf("%w: method %q", ErrUnknownAction, method)
+	}
+	return ParseAction(strings.TrimPrefix(method, SubmitPrefix))
+}
+
+// Actions returns registered actions sorted by name
+func Actions() []Action {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	list := make([]Action, 0, len(actions))
+	for action := range actions {
+		list = append(list, action)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
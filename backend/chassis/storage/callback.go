@@ -0,0 +1,38 @@
+package storage
+
+// Callback - destination of task's final result, URL or reply queue
+type Callback struct {
+	URL   string `json:"url,omitempty"`
+	Queue string `json:"queue,omitempty"`
+	// CorrelationID - JSON-RPC id of submit, it's the id of delivered response
+	CorrelationID string `json:"correlationID,omitempty"`
+}
+
+// Empty reports whether task has no callback
+func (c Callback) Empty() bool {
+	return c.URL == "" && c.Queue == ""
+}
+
+// DeliveryState - possible states of callback's delivery
+type DeliveryState string
+
+const (
+	PENDING   DeliveryState = "PENDING"
+	DELIVERED DeliveryState = "DELIVERED"
+	FAILED    DeliveryState = "FAILED"
+)
+
+// Delivery - result of finished task waiting in outbox for its callback, row is kept as delivery log
+type Delivery struct {
+	ID       int
+	TaskID   int
+	Callback Callback
+	// Payload - JSON-RPC response with task's result or error
+	Payload  string
+	Attempts int
+	State    DeliveryState
+	// Status - HTTP status of last attempt, 0 for queues
+	Status int
+	// Error of last attempt
+	Error string
+}
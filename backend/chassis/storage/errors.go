@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+)
+
+const (
+	// uniqueViolation - postgres SQLSTATE for unique constraint violation
+	uniqueViolation = "23505"
+)
+
+var (
+	// ErrNoTask - there is no task ready to be acquired
+	ErrNoTask = errors.New("no task")
+	// ErrDuplicate - task with the same idempotency key is within its dedup window
+	ErrDuplicate = errors.New("duplicated task")
+	// ErrStaleResult - result doesn't belong to task's current attempt
+	ErrStaleResult = errors.New("stale result")
+	// ErrUnknownAction - action is not registered in the catalog
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrNoSuchTask - task doesn't exist or was cleaned
+	ErrNoSuchTask = errors.New("no such task")
+	// ErrTaskFinished - task is in final state and can't be changed
+	ErrTaskFinished = errors.New("task is finished")
+)
+
+// DuplicateError - task was rejected by idempotency key
+type DuplicateError struct {
+	Action Action
+	Key    string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s: action=%s key=%s", ErrDuplicate, e.Action, e.Key)
+}
+
+// Is makes DuplicateError match ErrDuplicate
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// StaleResultError - result was not applied, task is not acquired with that attempt anymore
+type StaleResultError struct {
+	TaskID  int
+	Attempt int
+}
+
+func (e *StaleResultError) Error() string {
+	return fmt.Sprintf("%s: taskID=%d attempt=%d", ErrStaleResult, e.TaskID, e.Attempt)
+}
+
+// Is makes StaleResultError match ErrStaleResult
+func (e *StaleResultError) Is(target error) bool {
+	return target == ErrStaleResult
+}
+
+// IsUniqueViolation reports whether err is caused by postgres unique constraint
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
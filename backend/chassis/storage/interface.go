@@ -1,5 +1,9 @@
 package storage
 
+import (
+	"context"
+)
+
 const (
 	// TaskMaxRetries before setting CRITICAL_ERROR state,
 	TaskMaxRetries = "10"
@@ -7,7 +11,8 @@ const (
 
 // Config - ...
 type Config struct {
-	DSN string
+	DSN      string
+	MaxConns int
 }
 
 // TaskRepository - ...
@@ -15,6 +20,14 @@ type TaskRepository interface {
 	Enqueue(*Task) error
 	SelectTask() (*Task, error)
 	SetTaskResult(*Task) error
+	GetTask(id int) (*Task, error)
+	CancelTask(id int) error
 	RepairStaleTasks(timeout int, batchSize int) (int, error)
 	CleanOldTasks(expiration int) (int, error)
+	CleanExpiredKeys() (int, error)
+	AcquireDeliveries(batchSize int, lease int) ([]*Delivery, error)
+	SetDeliveryResult(delivery *Delivery, retryDelay int) error
+	CleanDeliveries(expiration int) (int, error)
+	Stats() (*TaskStats, error)
+	Ping(ctx context.Context) error
 }
@@ -3,24 +3,50 @@ package storage
 import (
 	"context"
 	"errors"
-	"strconv"
+	"fmt"
+	"time"
 
-	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// deliveryPayload - v0 JSON-RPC response of finished task `t`, it's sent to task's callback
+const deliveryPayload = `jsonb_strip_nulls(jsonb_build_object(
+	  'jsonrpc', '2.0',
+	  'id', coalesce(t.callback->>'correlationID', t.id::text),
+	  'taskID', t.id::text,
+	  'state', t.state,
+	  'result', CASE WHEN t.state = 'SUCCESS' THEN t.result || jsonb_build_object('attempt', t.attempts::text) END,
+	  'error', CASE WHEN t.state = 'SUCCESS' THEN NULL ELSE t.error || jsonb_build_object('attempt', t.attempts::text) END
+	))`
+
+// enqueueDelivery adds delivery of task $1 to outbox if it's finished and has a callback
+const enqueueDelivery = `
+	insert into t_callback(task_id, callback, payload)
+	select t.id, t.callback, ` + deliveryPayload + `
+	from t_scheduler t where t.id = $1 and t.state in ('SUCCESS', 'CRITICAL_ERROR', 'CANCELED') and t.callback <> '{}'::jsonb;
+	`
+
 // PGRepository - ...
 type PGRepository struct {
 	pool *pgxpool.Pool
 }
 
-// InitPGRepository - ...
-func InitPGRepository(cfg Config) (TaskRepository, error) {
+// NewPool connects pool limited by cfg.MaxConns, it's also used by handlers' resources
+func NewPool(cfg Config) (*pgxpool.Pool, error) {
 	poolConfig, err := pgxpool.ParseConfig(cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
-	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxConns)
+	}
+	return pgxpool.ConnectConfig(context.Background(), poolConfig)
+}
+
+// InitPGRepository - ...
+func InitPGRepository(cfg Config) (TaskRepository, error) {
+	pool, err := NewPool(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -31,16 +57,45 @@ func InitPGRepository(cfg Config) (TaskRepository, error) {
 
 // Enqueue - ...
 func (repo *PGRepository) Enqueue(task *Task) error {
-	query := `insert into t_scheduler(action, payload, state) values ($1, $2, $3)`
-	_, err := repo.pool.Exec(context.Background(), query, task.Action, task.Payload, "SCHEDULED")
-	//ERROR: duplicate key value violates unique constraint "scheduler_object_index" (SQLSTATE 23505)
+	ctx := context.Background()
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+	insert into t_scheduler(action, payload, state, trace, callback, priority, delayed_dt)
+	values ($1, $2, $3, $4, $5, $6, localtimestamp + concat($7::int, ' seconds')::INTERVAL)
+	returning id
+	`
+	err = tx.QueryRow(
+		ctx, query,
+		task.Action, task.Payload, "SCHEDULED", task.Trace, task.Callback, task.Priority, task.Delay,
+	).Scan(&task.ID)
 	if err != nil {
-		if err.Error() == `ERROR: duplicate key value violates unique constraint "scheduler_object_index" (SQLSTATE 23505)` {
-			return errors.New("duplicated task")
-		}
 		return err
 	}
-	return nil
+	if task.IdempotencyKey != "" {
+		// Key is taken over only when the previous one is out of its dedup window
+		query = `
+		insert into t_idempotency(action, key, task_id, expires_dt)
+		values ($1, $2, $3, localtimestamp + concat($4::int, ' seconds')::INTERVAL)
+		on conflict (action, key) do update
+		set
+		  task_id = excluded.task_id,
+		  expires_dt = excluded.expires_dt
+		where t_idempotency.expires_dt < localtimestamp;
+		`
+		tag, err := tx.Exec(ctx, query, task.Action, task.IdempotencyKey, task.ID, task.DedupWindow)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return &DuplicateError{Action: task.Action, Key: task.IdempotencyKey}
+		}
+	}
+	return tx.Commit(ctx)
 }
 
 // SelectTask - ...
@@ -48,10 +103,11 @@ func (repo *PGRepository) SelectTask() (*Task, error) {
 	var task Task
 	query := `
 	with task as (
-        select id, action, payload, state, attempts 
+        select id, action, payload, state, attempts, trace
 		from t_scheduler where 
 			state in ('SCHEDULED', 'ERROR')
 			and delayed_dt < localtimestamp
+	    order by priority desc, id
 	    limit 1 for update skip locked
 	) update t_scheduler
 	set 
@@ -61,7 +117,7 @@ func (repo *PGRepository) SelectTask() (*Task, error) {
 		attempts = t_scheduler.attempts +1
 	from task
 	where t_scheduler.id = task.id
-	returning t_scheduler.id, t_scheduler.action, t_scheduler.payload, t_scheduler.state, t_scheduler.attempts;
+	returning t_scheduler.id, t_scheduler.action, t_scheduler.payload, t_scheduler.state, t_scheduler.attempts, t_scheduler.trace;
 	`
 	err := repo.pool.QueryRow(context.Background(), query).Scan(
 		&task.ID,
@@ -69,63 +125,177 @@ func (repo *PGRepository) SelectTask() (*Task, error) {
 		&task.Payload,
 		&task.State,
 		&task.Attempts,
+		&task.Trace,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoTask
+	}
 	if err != nil {
 		return nil, err
 	}
-	task.Payload["attempt"] = strconv.Itoa(task.Attempts) // Versioning
 	return &task, nil
 }
 
 // SetTaskResult - ...
 func (repo *PGRepository) SetTaskResult(task *Task) error {
-	var tag pgconn.CommandTag
-	var err error
 	if len(task.Error) == 0 {
-		attempt := task.Result["attempt"]
-		// delete(task.Result, "attempt")
-		query := `
-		update t_scheduler
-		set 
-		  state = 'SUCCESS', 
-		  result = $3,
-		  error = '{}',
-		  updated_dt = localtimestamp, 
-		  delayed_dt = null
-		where id = $1 and state = 'ACQUIRED' and attempts = $2;
-		`
-		tag, err = repo.pool.Exec(context.Background(), query, task.ID, attempt, task.Result)
-	} else {
-		attempt := task.Error["attempt"]
-		query := `
-		update t_scheduler
-		set 
-		  state = CASE WHEN attempts < $1 THEN 'ERROR' ELSE 'CRITICAL_ERROR' END,
-		  error = $2,
-		  updated_dt = localtimestamp, 
-		  delayed_dt = CASE WHEN attempts < $1 THEN localtimestamp + concat(5 * attempts, ' seconds')::INTERVAL ELSE null END
-		where id = $3 and state = 'ACQUIRED' and attempts = $4;
+		return repo.setSuccess(task)
+	}
+	ctx := context.Background()
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+	update t_scheduler
+	set 
+	  state = CASE WHEN attempts < $1 AND NOT $5 THEN 'ERROR' ELSE 'CRITICAL_ERROR' END,
+	  error = $2,
+	  updated_dt = localtimestamp, 
+	  delayed_dt = CASE WHEN attempts < $1 AND NOT $5 THEN localtimestamp + concat(5 * attempts, ' seconds')::INTERVAL ELSE null END
+	where id = $3 and state = 'ACQUIRED' and attempts = $4
+	returning state;
+	`
+	var state State
+	err = tx.QueryRow(ctx, query, TaskMaxRetries, task.Error, task.ID, task.Attempts, task.Permanent).Scan(&state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &StaleResultError{TaskID: task.ID, Attempt: task.Attempts}
+	}
+	if err != nil {
+		return err
+	}
+	if state == CRITICAL_ERROR {
+		if _, err = tx.Exec(ctx, enqueueDelivery, task.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// setSuccess saves result and enqueues task's follow-up and callback's delivery in one transaction,
+// so redelivered result doesn't create them twice
+func (repo *PGRepository) setSuccess(task *Task) error {
+	ctx := context.Background()
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+	update t_scheduler
+	set 
+	  state = 'SUCCESS', 
+	  result = $3,
+	  error = '{}',
+	  updated_dt = localtimestamp, 
+	  delayed_dt = null
+	where id = $1 and state = 'ACQUIRED' and attempts = $2;
+	`
+	tag, err := tx.Exec(ctx, query, task.ID, task.Attempts, task.Result)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &StaleResultError{TaskID: task.ID, Attempt: task.Attempts}
+	}
+	if _, err = tx.Exec(ctx, enqueueDelivery, task.ID); err != nil {
+		return err
+	}
+	if task.FollowUp != nil {
+		query = `
+		insert into t_scheduler(action, payload, state, trace)
+		select action, $2, 'SCHEDULED', trace from t_scheduler where id = $1
 		`
-		tag, err = repo.pool.Exec(context.Background(), query, TaskMaxRetries, task.Error, task.ID, attempt)
+		_, err = tx.Exec(ctx, query, task.ID, task.FollowUp)
+		if err != nil {
+			return err
+		}
 	}
+	return tx.Commit(ctx)
+}
 
+// GetTask returns task's current state
+func (repo *PGRepository) GetTask(id int) (*Task, error) {
+	task := &Task{}
+	query := `
+	select id, action, payload, state, result, error, attempts, priority, trace, callback, created_dt, updated_dt
+	from t_scheduler where id = $1;
+	`
+	err := repo.pool.QueryRow(context.Background(), query, id).Scan(
+		&task.ID,
+		&task.Action,
+		&task.Payload,
+		&task.State,
+		&task.Result,
+		&task.Error,
+		&task.Attempts,
+		&task.Priority,
+		&task.Trace,
+		&task.Callback,
+		&task.CreatedDt,
+		&task.UpdatedDt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSuchTask
+	}
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// CancelTask stops task which isn't finished, result of its running attempt becomes stale
+func (repo *PGRepository) CancelTask(id int) error {
+	ctx := context.Background()
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+	update t_scheduler
+	set
+	  state = 'CANCELED',
+	  error = '{"code": "0", "message": "canceled"}',
+	  updated_dt = localtimestamp,
+	  delayed_dt = null
+	where id = $1 and state in ('SCHEDULED', 'ACQUIRED', 'ERROR');
+	`
+	tag, err := tx.Exec(ctx, query, id)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return errors.New("zero rows affected")
+		var state State
+		err = tx.QueryRow(ctx, `select state from t_scheduler where id = $1`, id).Scan(&state)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoSuchTask
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: taskID=%d state=%s", ErrTaskFinished, id, state)
+	}
+	if _, err = tx.Exec(ctx, enqueueDelivery, id); err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
 // RepairStaleTasks ...
 func (repo *PGRepository) RepairStaleTasks(timeout int, batchSize int) (int, error) {
+	// Tasks moved to CRITICAL_ERROR get their callback's delivery in the same statement
 	query := `
 	with tasks as (
         select id, attempts 
 	    from t_scheduler where state = 'ACQUIRED' and updated_dt < localtimestamp - concat($1::int, ' seconds')::INTERVAL
 	    limit $2 for update skip locked
-	) update t_scheduler
+	), repaired as (
+	update t_scheduler
 	set 
 	  state = CASE WHEN t_scheduler.attempts + 1 < $3 THEN 'ERROR' ELSE 'CRITICAL_ERROR' END,
 	  updated_dt = localtimestamp, 
@@ -133,13 +303,21 @@ func (repo *PGRepository) RepairStaleTasks(timeout int, batchSize int) (int, err
 	  attempts = t_scheduler.attempts +1, 
 	  error = '{"code": "0", "message": "stale task"}'
 	from tasks
-	where t_scheduler.id = tasks.id;
+	where t_scheduler.id = tasks.id
+	returning t_scheduler.*
+	), deliveries as (
+	  insert into t_callback(task_id, callback, payload)
+	  select t.id, t.callback, ` + deliveryPayload + `
+	  from repaired t where t.state = 'CRITICAL_ERROR' and t.callback <> '{}'::jsonb
+	)
+	select count(*) from repaired;
 	`
-	cmdTag, err := repo.pool.Exec(context.Background(), query, timeout, batchSize, TaskMaxRetries)
+	var repaired int
+	err := repo.pool.QueryRow(context.Background(), query, timeout, batchSize, TaskMaxRetries).Scan(&repaired)
 	if err != nil {
 		return 0, err
 	}
-	return int(cmdTag.RowsAffected()), nil
+	return repaired, nil
 }
 
 // CleanOldTasks ...
@@ -147,7 +325,7 @@ func (repo *PGRepository) CleanOldTasks(expiration int) (int, error) {
 	query := `
 	delete from t_scheduler 
 	where 
-		state = 'SUCCESS' and 
+		state in ('SUCCESS', 'CANCELED') and 
 		updated_dt < localtimestamp - concat($1::int, ' seconds')::INTERVAL;
 	`
 	cmdTag, err := repo.pool.Exec(context.Background(), query, expiration)
@@ -156,3 +334,120 @@ func (repo *PGRepository) CleanOldTasks(expiration int) (int, error) {
 	}
 	return int(cmdTag.RowsAffected()), nil
 }
+
+// CleanExpiredKeys ...
+func (repo *PGRepository) CleanExpiredKeys() (int, error) {
+	query := `delete from t_idempotency where expires_dt < localtimestamp;`
+	cmdTag, err := repo.pool.Exec(context.Background(), query)
+	if err != nil {
+		return 0, err
+	}
+	return int(cmdTag.RowsAffected()), nil
+}
+
+// AcquireDeliveries takes pending deliveries for lease seconds, they are retried after it if notifier dies
+func (repo *PGRepository) AcquireDeliveries(batchSize int, lease int) ([]*Delivery, error) {
+	query := `
+	with deliveries as (
+	    select id
+	    from t_callback where state = 'PENDING' and delayed_dt < localtimestamp
+	    order by delayed_dt
+	    limit $1 for update skip locked
+	) update t_callback
+	set
+	  attempts = t_callback.attempts + 1,
+	  updated_dt = localtimestamp,
+	  delayed_dt = localtimestamp + concat($2::int, ' seconds')::INTERVAL
+	from deliveries
+	where t_callback.id = deliveries.id
+	returning t_callback.id, t_callback.task_id, t_callback.callback, t_callback.payload::text, t_callback.attempts;
+	`
+	rows, err := repo.pool.Query(context.Background(), query, batchSize, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		delivery := &Delivery{State: PENDING}
+		err := rows.Scan(&delivery.ID, &delivery.TaskID, &delivery.Callback, &delivery.Payload, &delivery.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// SetDeliveryResult saves outcome of delivery's attempt, pending delivery is retried after retryDelay seconds
+func (repo *PGRepository) SetDeliveryResult(delivery *Delivery, retryDelay int) error {
+	query := `
+	update t_callback
+	set
+	  state = $3,
+	  status = $4,
+	  error = $5,
+	  updated_dt = localtimestamp,
+	  delayed_dt = localtimestamp + concat($6::int, ' seconds')::INTERVAL
+	where id = $1 and attempts = $2 and state = 'PENDING';
+	`
+	_, err := repo.pool.Exec(
+		context.Background(), query,
+		delivery.ID, delivery.Attempts, delivery.State, delivery.Status, delivery.Error, retryDelay,
+	)
+	return err
+}
+
+// CleanDeliveries removes delivered and failed deliveries
+func (repo *PGRepository) CleanDeliveries(expiration int) (int, error) {
+	query := `
+	delete from t_callback
+	where
+		state <> 'PENDING' and
+		updated_dt < localtimestamp - concat($1::int, ' seconds')::INTERVAL;
+	`
+	cmdTag, err := repo.pool.Exec(context.Background(), query, expiration)
+	if err != nil {
+		return 0, err
+	}
+	return int(cmdTag.RowsAffected()), nil
+}
+
+// Stats ...
+func (repo *PGRepository) Stats() (*TaskStats, error) {
+	query := `
+	select
+		state,
+		count(*),
+		coalesce(extract(epoch from localtimestamp - min(created_dt)), 0)::float8
+	from t_scheduler
+	group by state;
+	`
+	rows, err := repo.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := &TaskStats{
+		States: map[State]int{},
+	}
+	for rows.Next() {
+		var state State
+		var count int
+		var age float64
+		if err := rows.Scan(&state, &count, &age); err != nil {
+			return nil, err
+		}
+		stats.States[state] = count
+		if state == SCHEDULED {
+			stats.OldestScheduledAge = time.Duration(age * float64(time.Second))
+		}
+	}
+	return stats, rows.Err()
+}
+
+// Ping ...
+func (repo *PGRepository) Ping(ctx context.Context) error {
+	_, err := repo.pool.Exec(ctx, "select 1")
+	return err
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
 )
 
 // State - submitted record's possible states
@@ -13,9 +15,10 @@ const (
 	SUCCESS        State = "SUCCESS"
 	ERROR          State = "ERROR"
 	CRITICAL_ERROR State = "CRITICAL_ERROR"
+	CANCELED       State = "CANCELED"
 )
 
-// Action - scheduler's possible actions
+// Action - scheduler's possible actions, built-in ones are below, custom are added with RegisterAction
 type Action string
 
 const (
@@ -27,11 +30,33 @@ const (
 type Task struct {
 	ID        int
 	Action    Action
-	Payload   map[string]string
+	Payload   protocol.Payload
 	CreatedDt time.Time
 	UpdatedDt time.Time
 	State     State
-	Result    map[string]string
-	Error     map[string]string
+	Result    protocol.Payload
+	Error     protocol.Payload
 	Attempts  int
+	// Permanent - task's error must not be retried
+	Permanent bool
+	// FollowUp - payload of a new task of the same action, it's enqueued with successful result
+	FollowUp protocol.Payload
+	// Trace - serialized trace context of the submit span
+	Trace map[string]string
+	// Callback receives task's result when it's SUCCESS, CRITICAL_ERROR or CANCELED
+	Callback Callback
+	// Priority - tasks with higher priority are acquired first
+	Priority int
+	// Delay - seconds before the first attempt, it's used by Enqueue only
+	Delay int
+
+	// IdempotencyKey deduplicates submits of the same action within DedupWindow seconds.
+	IdempotencyKey string
+	DedupWindow    int
+}
+
+// TaskStats - snapshot of task counts
+type TaskStats struct {
+	States             map[State]int
+	OldestScheduledAge time.Duration
 }
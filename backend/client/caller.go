@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/queue"
+)
+
+// ErrTimeout - reply didn't come in time, task may still be processed
+var ErrTimeout = errors.New("reply timeout")
+
+// Caller submits tasks and awaits their replies, like RPC.
+// Reply queue must be used by one caller only: replies of unknown calls are dropped.
+type Caller struct {
+	submit  queue.Client
+	replies queue.Client
+	replyTo string
+	// Version of submits, protocol.V0 by default, replies of any version are accepted
+	Version int
+
+	mu      sync.Mutex
+	waiting map[string]chan *protocol.Response
+}
+
+// NewCaller returns caller which submits to submitter's queue and receives replies from replyTo queue,
+// replyTo must be one of resulter.callbacks.queues. Replies are received while Run is running.
+func NewCaller(submit queue.Client, replies queue.Client, replyTo string) *Caller {
+	return &Caller{
+		submit:  submit,
+		replies: replies,
+		replyTo: replyTo,
+		waiting: map[string]chan *protocol.Response{},
+	}
+}
+
+// correlationID returns random id of a call
+func correlationID() (string, error) {
+	buff := make([]byte, 16)
+	if _, err := rand.Read(buff); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buff), nil
+}
+
+// Call submits action with params and waits for task's final response.
+// Params are struct or map marshaled to JSON object.
+// Error response of a failed task is returned as is, err is only about the call itself.
+func (c *Caller) Call(ctx context.Context, action string, params interface{}, timeout time.Duration) (*protocol.Response, error) {
+	payload, err := protocol.ToPayload(params)
+	if err != nil {
+		return nil, err
+	}
+	id, err := correlationID()
+	if err != nil {
+		return nil, err
+	}
+	reply := make(chan *protocol.Response, 1)
+	c.mu.Lock()
+	c.waiting[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiting, id)
+		c.mu.Unlock()
+	}()
+
+	request := &protocol.Request{
+		Version: c.Version,
+		ID:      id,
+		Method:  "submit:" + action,
+		Params:  payload,
+		ReplyTo: c.replyTo,
+	}
+	body, err := request.JSON()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.submit.SendMessage(body); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case response := <-reply:
+		return response, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: %s", ErrTimeout, timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// dispatch passes reply to its call
+func (c *Caller) dispatch(response *protocol.Response) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, ok := c.waiting[response.ID]
+	if !ok {
+		return false
+	}
+	select {
+	case reply <- response:
+	default:
+		// Duplicate of already received reply
+	}
+	return true
+}
+
+// Run receives replies until ctx is done
+func (c *Caller) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		msg, err := c.replies.ReceiveMessage()
+		if errors.Is(err, queue.ErrNoMessage) {
+			continue
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"event": "receive_reply_failed",
+			}).Error(err)
+			time.Sleep(time.Second)
+			continue
+		}
+		response := &protocol.Response{}
+		if err := response.FromJSON(msg.Body); err != nil {
+			log.WithFields(log.Fields{
+				"event": "received_broken_reply",
+			}).Error(err)
+		} else if !c.dispatch(response) {
+			// Call is over: timed out or replied already
+			log.WithFields(log.Fields{
+				"event":  "unexpected_reply",
+				"id":     response.ID,
+				"taskID": response.TaskID,
+			}).Warn("drop reply without call")
+		}
+		if err := c.replies.Acknowledge(msg); err != nil {
+			log.WithFields(log.Fields{
+				"event": "ack_reply_failed",
+			}).Error(err)
+		}
+	}
+}
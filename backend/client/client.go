@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+var (
+	// ErrUnsupported - transport can't do the call, e.g. queue transport can't get task's state
+	ErrUnsupported = errors.New("not supported by transport")
+	// ErrNotFound - task doesn't exist or was cleaned
+	ErrNotFound = errors.New("task not found")
+	// ErrDuplicate - task with the same idempotency key is within its dedup window
+	ErrDuplicate = errors.New("duplicated task")
+	// ErrFinished - task is finished and can't be canceled
+	ErrFinished = errors.New("task is finished")
+	// ErrRejected - submit is invalid, e.g. its action is unknown
+	ErrRejected = errors.New("submit rejected")
+)
+
+// Final states of tasks
+const (
+	Success       = "SUCCESS"
+	CriticalError = "CRITICAL_ERROR"
+	Canceled      = "CANCELED"
+)
+
+// Task - task's state
+type Task = protocol.Task
+
+// Transport delivers client's calls to scheduler: inbound queue or HTTP API
+type Transport interface {
+	// Submit returns task's ID, it's empty if transport doesn't know it
+	Submit(ctx context.Context, request *protocol.Request) (string, error)
+	Get(ctx context.Context, id string) (*Task, error)
+	Cancel(ctx context.Context, id string) error
+}
+
+// Option - setting of submitted task
+type Option func(*protocol.Request)
+
+// WithPriority - tasks with higher priority are acquired first, default is 0
+func WithPriority(priority int) Option {
+	return func(request *protocol.Request) {
+		request.Priority = priority
+	}
+}
+
+// WithDelay postpones the first attempt, delay is rounded up to seconds
+func WithDelay(delay time.Duration) Option {
+	return func(request *protocol.Request) {
+		request.Delay = int((delay + time.Second - 1) / time.Second)
+	}
+}
+
+// WithIdempotencyKey - submits with the same key are deduplicated within submitter's window
+func WithIdempotencyKey(key string) Option {
+	return func(request *protocol.Request) {
+		request.IdempotencyKey = key
+	}
+}
+
+// WithCallback - URL which receives task's final response
+func WithCallback(url string) Option {
+	return func(request *protocol.Request) {
+		request.Callback = &protocol.Callback{URL: url}
+	}
+}
+
+// Client submits and tracks tasks without knowledge of message formats
+type Client struct {
+	transport Transport
+	// PollInterval - period of Wait's checks
+	PollInterval time.Duration
+	// Version of submits, protocol.V0 by default, set protocol.V1 after submitters accept it
+	Version int
+}
+
+// New returns client over transport, e.g. client.New(client.NewHTTP("http://scheduler:2112", nil, token))
+func New(transport Transport) *Client {
+	return &Client{
+		transport:    transport,
+		PollInterval: time.Second,
+	}
+}
+
+// Submit enqueues task of action, params are struct or map marshaled to JSON object,
+// e.g. client.Submit(ctx, "export", map[string]interface{}{"objectID": 23}).
+// Returned ID is empty if transport doesn't know it.
+func (c *Client) Submit(ctx context.Context, action string, params interface{}, opts ...Option) (string, error) {
+	payload, err := protocol.ToPayload(params)
+	if err != nil {
+		return "", err
+	}
+	request := &protocol.Request{
+		Version: c.Version,
+		Method:  "submit:" + action,
+		Params:  payload,
+	}
+	for _, opt := range opts {
+		opt(request)
+	}
+	return c.transport.Submit(ctx, request)
+}
+
+// Get returns task's current state
+func (c *Client) Get(ctx context.Context, id string) (*Task, error) {
+	return c.transport.Get(ctx, id)
+}
+
+// Wait polls task until it's finished: SUCCESS, CRITICAL_ERROR or CANCELED, ctx limits waiting
+func (c *Client) Wait(ctx context.Context, id string) (*Task, error) {
+	for {
+		task, err := c.transport.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch task.State {
+		case Success, CriticalError, Canceled:
+			return task, nil
+		}
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// Cancel stops task which isn't finished, result of its running attempt is ignored
+func (c *Client) Cancel(ctx context.Context, id string) error {
+	return c.transport.Cancel(ctx, id)
+}
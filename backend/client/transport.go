@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/queue"
+)
+
+// queueTransport submits to submitter's inbound queue, tasks can't be tracked through it
+type queueTransport struct {
+	cli queue.Client
+}
+
+// NewQueue returns transport over submitter's inbound queue, it supports Submit only
+func NewQueue(cli queue.Client) Transport {
+	return &queueTransport{cli: cli}
+}
+
+func (t *queueTransport) Submit(ctx context.Context, request *protocol.Request) (string, error) {
+	body, err := request.JSON()
+	if err != nil {
+		return "", err
+	}
+	return "", t.cli.SendMessage(body)
+}
+
+func (t *queueTransport) Get(ctx context.Context, id string) (*Task, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *queueTransport) Cancel(ctx context.Context, id string) error {
+	return ErrUnsupported
+}
+
+// httpTransport calls submitter's HTTP API
+type httpTransport struct {
+	baseURL string
+	cli     *http.Client
+	token   string
+}
+
+// NewHTTP returns transport over submitter's HTTP API at baseURL, e.g. "http://scheduler:2112".
+// http.DefaultClient is used if cli is nil, token is submitter.api.token.
+func NewHTTP(baseURL string, cli *http.Client, token string) Transport {
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	return &httpTransport{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v1/tasks",
+		cli:     cli,
+		token:   token,
+	}
+}
+
+// do sends request and decodes successful response to result, API errors are mapped by status
+func (t *httpTransport) do(ctx context.Context, method string, path string, body interface{}, result interface{}, conflict error) error {
+	var reader io.Reader
+	if body != nil {
+		bin, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bin)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if t.token != "" {
+		request.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	response, err := t.cli.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		if result == nil {
+			return nil
+		}
+		return json.NewDecoder(response.Body).Decode(result)
+	}
+	apiError := struct {
+		Error string `json:"error"`
+	}{}
+	text, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+	if json.Unmarshal(text, &apiError) != nil || apiError.Error == "" {
+		apiError.Error = strings.TrimSpace(string(text))
+	}
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, apiError.Error)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", conflict, apiError.Error)
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrRejected, apiError.Error)
+	default:
+		return fmt.Errorf("scheduler api returned %d: %s", response.StatusCode, apiError.Error)
+	}
+}
+
+func (t *httpTransport) Submit(ctx context.Context, request *protocol.Request) (string, error) {
+	created := struct {
+		ID string `json:"id"`
+	}{}
+	err := t.do(ctx, http.MethodPost, "", request, &created, ErrDuplicate)
+	return created.ID, err
+}
+
+func (t *httpTransport) Get(ctx context.Context, id string) (*Task, error) {
+	task := &Task{}
+	err := t.do(ctx, http.MethodGet, "/"+url.PathEscape(id), nil, task, ErrFinished)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (t *httpTransport) Cancel(ctx context.Context, id string) error {
+	return t.do(ctx, http.MethodDelete, "/"+url.PathEscape(id), nil, nil, ErrFinished)
+}
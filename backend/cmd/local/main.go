@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"strconv"
 	"time"
@@ -11,7 +12,7 @@ import (
 	"github.com/jackc/pgx/v4"
 
 	"github.com/freundallein/scheduler/backend/chassis/config"
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
 )
 
@@ -49,7 +50,7 @@ func insert(workerID int, inbound chan *protocol.Request) {
 		}
 		message := protocol.Request{
 			Method: "submit:export",
-			Params: map[string]string{"objectID": strconv.Itoa(insertedID)},
+			Params: protocol.NewPayload(map[string]string{"objectID": strconv.Itoa(insertedID)}),
 		}
 		inbound <- &message
 	}
@@ -66,12 +67,13 @@ func process(workerID int, outbound chan *protocol.Request, results chan *protoc
 	for {
 		select {
 		case request := <-outbound:
-			var action storage.Action
-			switch request.Method {
-			case string(storage.EXPORT):
-				action = storage.EXPORT
-			default:
-				action = storage.DUMMY
+			action, err := storage.ParseAction(request.Method)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"event":  "unknown_action",
+					"worker": workerID,
+				}).Error(err)
+				continue
 			}
 			log.WithFields(log.Fields{
 				"event":    "receive_message",
@@ -79,53 +81,54 @@ func process(workerID int, outbound chan *protocol.Request, results chan *protoc
 				"action":   action,
 				"taskID":   request.ID,
 				"module":   "processor",
-				"objectID": request.Params["objectID"],
+				"objectID": request.Params.Get("objectID"),
 			}).Debug(request)
 			response := &protocol.Response{
-				ID: request.ID,
+				ID:      request.ID,
+				Attempt: request.Attempt,
 			}
 			var object storage.Object
 			query := `select id, data from t_object where id=$1`
-			err = conn.QueryRow(context.Background(), query, request.Params["objectID"]).Scan(&object.ID, &object.Data)
-			err = monkey.RandomizeError(err)
+			err = conn.QueryRow(context.Background(), query, request.Params.Get("objectID")).Scan(&object.ID, &object.Data)
+			err = fault.Inject("worker.export.select", err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":    "select_object_failed",
 					"worker":   workerID,
 					"taskID":   request.ID,
-					"objectID": request.Params["objectID"],
+					"objectID": request.Params.Get("objectID"),
 					"module":   "processor",
-					"attempt":  request.Params["attempt"],
+					"attempt":  request.Attempt,
 				}).Error(err)
-				response.Error = map[string]string{"code": "1", "message": err.Error(), "attempt": request.Params["attempt"]}
+				response.Error = protocol.NewPayload(map[string]string{"code": "1", "message": err.Error()})
 				results <- response
 				continue
 			}
 			var returnedID int
 			query = `insert into t_exported_object(id, data) values ($1, $2) returning id`
 			err = conn.QueryRow(context.Background(), query, object.ID, object.Data).Scan(&returnedID)
-			err = monkey.RandomizeError(err)
-			if err != nil && err.Error() != `ERROR: duplicate key value violates unique constraint "t_exported_object_pkey" (SQLSTATE 23505)` {
+			err = fault.Inject("worker.export.insert", err)
+			if err != nil && !storage.IsUniqueViolation(err) {
 				log.WithFields(log.Fields{
 					"event":    "insert_object_failed",
 					"worker":   workerID,
 					"taskID":   request.ID,
-					"objectID": request.Params["objectID"],
+					"objectID": request.Params.Get("objectID"),
 					"module":   "processor",
-					"attempt":  request.Params["attempt"],
+					"attempt":  request.Attempt,
 				}).Error(err)
-				response.Error = map[string]string{"code": "2", "message": err.Error(), "attempt": request.Params["attempt"]}
+				response.Error = protocol.NewPayload(map[string]string{"code": "2", "message": err.Error()})
 				results <- response
 				continue
 			}
-			response.Result = map[string]string{"result": "success", "attempt": request.Params["attempt"]}
+			response.Result = protocol.NewPayload(map[string]string{"result": "success"})
 			log.WithFields(log.Fields{
 				"event":    "object_processed",
 				"worker":   workerID,
 				"taskID":   request.ID,
 				"module":   "processor",
-				"objectID": request.Params["objectID"],
-				"attempt":  request.Params["attempt"],
+				"objectID": request.Params.Get("objectID"),
+				"attempt":  request.Attempt,
 			}).Debug("successfully export object")
 			results <- response
 		}
@@ -140,6 +143,7 @@ func main() {
 		}).Fatal(err)
 	}
 	log.Init("local", appCfg)
+	fault.Init(appCfg)
 	log.WithFields(log.Fields{
 		"event": "init_service",
 	}).Debug("service initialized")
@@ -189,7 +193,7 @@ func submit(repo storage.TaskRepository, workerID int, inbound chan *protocol.Re
 	for {
 		select {
 		case request := <-inbound:
-			if _, ok := request.Params["objectID"]; !ok {
+			if !request.Params.Has("objectID") {
 				log.WithFields(log.Fields{
 					"event":  "unsupported_message",
 					"worker": workerID,
@@ -197,19 +201,21 @@ func submit(repo storage.TaskRepository, workerID int, inbound chan *protocol.Re
 				}).Error("no objectID supported")
 				continue
 			}
-			var action storage.Action
-			switch request.Method {
-			case "submit:export":
-				action = storage.EXPORT
-			default:
-				action = storage.DUMMY
+			action, err := storage.ParseSubmit(request.Method)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"event":  "unknown_action",
+					"worker": workerID,
+					"module": "submitter",
+				}).Error(err)
+				continue
 			}
 			log.WithFields(log.Fields{
 				"event":    "receive_message",
 				"module":   "submitter",
 				"worker":   workerID,
 				"action":   action,
-				"objectID": request.Params["objectID"],
+				"objectID": request.Params.Get("objectID"),
 			}).Debug(request)
 			task := &storage.Task{
 				Action:    action,
@@ -217,11 +223,11 @@ func submit(repo storage.TaskRepository, workerID int, inbound chan *protocol.Re
 				CreatedDt: time.Now(),
 				UpdatedDt: time.Now(),
 				State:     storage.SCHEDULED,
-				Result:    map[string]string{},
+				Result:    protocol.Payload{},
 				Attempts:  0,
 			}
-			err := repo.Enqueue(task)
-			err = monkey.RandomizeError(err)
+			err = repo.Enqueue(task)
+			err = fault.Inject("submitter.enqueue", err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":  "submit_failed",
@@ -236,9 +242,9 @@ func submit(repo storage.TaskRepository, workerID int, inbound chan *protocol.Re
 func schedule(repo storage.TaskRepository, workerID int, outbound chan *protocol.Request) {
 	for {
 		task, err := repo.SelectTask()
-		err = monkey.RandomizeError(err)
+		err = fault.Inject("scheduler.select", err)
 		if err != nil {
-			if err.Error() == "no rows in result set" {
+			if errors.Is(err, storage.ErrNoTask) {
 				log.WithFields(log.Fields{
 					"event":  "select_task_failed",
 					"module": "scheduler",
@@ -260,19 +266,13 @@ func schedule(repo storage.TaskRepository, workerID int, outbound chan *protocol
 			"worker":   workerID,
 			"taskID":   task.ID,
 			"action":   task.Action,
-			"objectID": task.Payload["objectID"],
+			"objectID": task.Payload.Get("objectID"),
 		}).Debug("acquire task")
-		var action string
-		switch task.Action {
-		case storage.EXPORT:
-			action = string(storage.EXPORT)
-		default:
-			action = string(storage.DUMMY)
-		}
 		message := protocol.Request{
-			Method: action,
-			Params: task.Payload,
-			ID:     strconv.Itoa(task.ID),
+			Method:  string(task.Action),
+			Params:  task.Payload,
+			ID:      strconv.Itoa(task.ID),
+			Attempt: task.Attempts,
 		}
 		outbound <- &message
 	}
@@ -289,7 +289,7 @@ func result(repo storage.TaskRepository, workerID int, results chan *protocol.Re
 			}).Debug("receive results for task")
 
 			taskID, err := strconv.Atoi(response.ID)
-			err = monkey.RandomizeError(err)
+			err = fault.Inject("resulter.parse_id", err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":  "received_broken_task_id",
@@ -300,12 +300,13 @@ func result(repo storage.TaskRepository, workerID int, results chan *protocol.Re
 				continue
 			}
 			task := &storage.Task{
-				ID:     taskID,
-				Result: response.Result,
-				Error:  response.Error,
+				ID:       taskID,
+				Result:   response.Result,
+				Error:    response.Error,
+				Attempts: response.Attempt,
 			}
 			err = repo.SetTaskResult(task)
-			err = monkey.RandomizeError(err)
+			err = fault.Inject("resulter.save", err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":  "set_task_failed",
@@ -324,7 +325,7 @@ func supervisor(repo storage.TaskRepository, workerID int) {
 		select {
 		case <-time.After(time.Second * 1):
 			repaired, err := repo.RepairStaleTasks(60, 10)
-			err = monkey.RandomizeError(err)
+			err = fault.Inject("supervisor.repair", err)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":  "stale_task_repair_failed",
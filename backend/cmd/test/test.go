@@ -15,7 +15,7 @@ import (
 )
 
 func main() {
-	appCfg, err := config.Read()
+	appCfg, err := config.Read("submitter")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"event": "config_read_failed",
@@ -25,18 +25,8 @@ func main() {
 	log.WithFields(log.Fields{
 		"event": "init_service",
 	}).Info("test service initialized")
-	queueCfg := queue.Config{
-		// Use submitter's cfg
-		Name:    appCfg.Submitter.Queuesrc.Name,
-		URL:     appCfg.Submitter.Queuesrc.URL,
-		Retries: appCfg.Submitter.Queuesrc.Retries,
-
-		//AWS specific
-		Region:             appCfg.AWS.Region,
-		CredentialsFile:    appCfg.AWS.CredentialsFile,
-		CredentialsProfile: appCfg.AWS.CredentialsProfile,
-	}
-	queueClient := queue.InitAWSQueue(queueCfg)
+	// Use submitter's cfg
+	queueClient := queue.InitAWSQueue(queue.NewConfig(appCfg.Submitter.Queuesrc, appCfg.AWS))
 
 	cfg := &test.Config{
 		QueueDst:   queueClient,
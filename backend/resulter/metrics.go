@@ -0,0 +1,33 @@
+package resulter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	resultsApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "resulter",
+		Name:      "results_applied_total",
+		Help:      "Results saved to storage.",
+	}, []string{"status"})
+	staleResults = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "resulter",
+		Name:      "stale_results_total",
+		Help:      "Results for tasks that are not acquired with that attempt anymore.",
+	})
+	followUps = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "resulter",
+		Name:      "follow_ups_total",
+		Help:      "Follow-up tasks enqueued with results, e.g. failed items of batches.",
+	})
+	callbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "resulter",
+		Name:      "callback_attempts_total",
+		Help:      "Attempts of callbacks' deliveries by transport and resulting state: pending (will be retried), delivered or failed.",
+	}, []string{"transport", "status"})
+)
@@ -0,0 +1,289 @@
+package resulter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/pool"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+const (
+	// maxDeliveryDelay - upper bound of backoff between delivery's attempts, seconds
+	maxDeliveryDelay = 3600
+	// maxErrorBody - bytes of callback's response kept in delivery log
+	maxErrorBody = 512
+)
+
+// Notifier - delivers results of finished tasks from outbox to their callbacks,
+// it runs apart from result handlers, so slow callbacks don't delay results
+type Notifier struct {
+	Repository  storage.TaskRepository
+	HTTP        *http.Client
+	Secret      []byte
+	Timeout     time.Duration
+	MaxAttempts int
+	BatchSize   int
+	Interval    time.Duration
+	Workers     int
+	// Version of sent responses, outbox keeps them as v0
+	Version int
+
+	queueURL string
+	aws      config.AWS
+	allowed  map[string]bool
+	origins  map[string]bool
+
+	mu     sync.Mutex
+	queues map[string]queue.Client
+}
+
+// NewNotifier builds notifier of resulter.callbacks
+func NewNotifier(appCfg *config.AppConfig, repo storage.TaskRepository) *Notifier {
+	settings := appCfg.Resulter.Callbacks
+	cli := &http.Client{
+		Timeout: time.Duration(settings.Timeout) * time.Second,
+		// Redirect could lead out of allowed origins, 3xx fails the attempt
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	notifier := &Notifier{
+		Repository:  repo,
+		HTTP:        cli,
+		Secret:      []byte(settings.Secret),
+		Timeout:     time.Duration(settings.Timeout) * time.Second,
+		MaxAttempts: settings.MaxAttempts,
+		BatchSize:   settings.BatchSize,
+		Interval:    time.Duration(settings.Interval) * time.Second,
+		Workers:     settings.Workers,
+		Version:     appCfg.Protocol.Version,
+		queueURL:    settings.QueueURL,
+		aws:         appCfg.AWS,
+		allowed:     map[string]bool{},
+		origins:     map[string]bool{},
+		queues:      map[string]queue.Client{},
+	}
+	for _, name := range settings.Queues {
+		notifier.allowed[name] = true
+	}
+	for _, origin := range settings.Origins {
+		if target, err := url.Parse(origin); err == nil {
+			notifier.origins[strings.ToLower(target.Scheme+"://"+target.Host)] = true
+		}
+	}
+	return notifier
+}
+
+// Sign returns X-Scheduler-Signature of body sent at timestamp, receivers check it with the shared secret
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// replyQueue returns client of allowed reply queue
+func (n *Notifier) replyQueue(name string) (queue.Client, error) {
+	if !n.allowed[name] {
+		return nil, fmt.Errorf("queue %q is not in resulter.callbacks.queues", name)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	cli, ok := n.queues[name]
+	if !ok {
+		cli = queue.InitAWSQueue(queue.NewConfig(config.Queue{Name: name, URL: n.queueURL}, n.aws))
+		n.queues[name] = cli
+	}
+	return cli, nil
+}
+
+// payload returns delivery's response in notifier's version
+func (n *Notifier) payload(delivery *storage.Delivery) (string, error) {
+	if n.Version == protocol.V0 {
+		return delivery.Payload, nil
+	}
+	response := &protocol.Response{}
+	if err := response.FromJSON(delivery.Payload); err != nil {
+		return "", err
+	}
+	response.Version = n.Version
+	return response.JSON()
+}
+
+// post sends payload to callback's URL of allowed origin, returns response status
+func (n *Notifier) post(delivery *storage.Delivery, payload string) (int, error) {
+	body := []byte(payload)
+	request, err := http.NewRequest(http.MethodPost, delivery.Callback.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if origin := strings.ToLower(request.URL.Scheme + "://" + request.URL.Host); !n.origins[origin] {
+		return 0, fmt.Errorf("origin %q is not in resulter.callbacks.origins", origin)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Scheduler-Delivery", strconv.Itoa(delivery.ID))
+	request.Header.Set("X-Scheduler-Task", strconv.Itoa(delivery.TaskID))
+	request.Header.Set("X-Scheduler-Timestamp", timestamp)
+	if len(n.Secret) > 0 {
+		request.Header.Set("X-Scheduler-Signature", Sign(n.Secret, timestamp, body))
+	}
+	response, err := n.HTTP.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		text, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+		return response.StatusCode, fmt.Errorf("callback returned %d: %s", response.StatusCode, bytes.TrimSpace(text))
+	}
+	return response.StatusCode, nil
+}
+
+// deliver makes one attempt of delivery and saves its outcome
+func (n *Notifier) deliver(delivery *storage.Delivery, workerID int) {
+	transport := "http"
+	payload, err := n.payload(delivery)
+	switch {
+	case err != nil:
+		// Broken payload fails every attempt, so delivery ends as FAILED
+	case delivery.Callback.Queue != "":
+		transport = "queue"
+		var cli queue.Client
+		cli, err = n.replyQueue(delivery.Callback.Queue)
+		if err == nil {
+			err = fault.Send("resulter.callback", func() error {
+				return cli.SendMessage(payload)
+			})
+		}
+	default:
+		err = fault.Send("resulter.callback", func() error {
+			var err error
+			delivery.Status, err = n.post(delivery, payload)
+			return err
+		})
+	}
+	retryDelay := 0
+	if err == nil {
+		delivery.State = storage.DELIVERED
+		delivery.Error = ""
+	} else {
+		delivery.Error = err.Error()
+		if delivery.Attempts >= n.MaxAttempts {
+			delivery.State = storage.FAILED
+		} else {
+			retryDelay = 5 << uint(delivery.Attempts-1)
+			if retryDelay > maxDeliveryDelay || retryDelay <= 0 {
+				retryDelay = maxDeliveryDelay
+			}
+		}
+		log.WithFields(log.Fields{
+			"event":    "callback_failed",
+			"worker":   workerID,
+			"taskID":   delivery.TaskID,
+			"delivery": delivery.ID,
+			"attempt":  delivery.Attempts,
+		}).Warn(err)
+	}
+	status := strings.ToLower(string(delivery.State))
+	callbacks.WithLabelValues(transport, status).Inc()
+	err = n.Repository.SetDeliveryResult(delivery, retryDelay)
+	err = fault.Inject("resulter.callback_save", err)
+	if err != nil {
+		// Delivery is retried after its lease, receivers dedupe by X-Scheduler-Delivery
+		log.WithFields(log.Fields{
+			"event":    "callback_save_failed",
+			"worker":   workerID,
+			"taskID":   delivery.TaskID,
+			"delivery": delivery.ID,
+		}).Error(err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"event":    "callback_" + status,
+		"worker":   workerID,
+		"taskID":   delivery.TaskID,
+		"delivery": delivery.ID,
+		"attempt":  delivery.Attempts,
+	}).Debug("save callback delivery")
+}
+
+// poll delivers a batch of pending deliveries, it returns false if outbox is empty
+func (n *Notifier) poll(workerID int) bool {
+	// Lease outlives attempts of the whole batch
+	lease := int(n.Timeout.Seconds())*n.BatchSize + 1
+	deliveries, err := n.Repository.AcquireDeliveries(n.BatchSize, lease)
+	err = fault.Inject("resulter.callback_acquire", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "callback_acquire_failed",
+			"worker": workerID,
+		}).Error(err)
+		return false
+	}
+	for _, delivery := range deliveries {
+		health.Beat("notifier", workerID)
+		recovery.Do("resulter", func() {
+			n.deliver(delivery, workerID)
+		})
+	}
+	return len(deliveries) > 0
+}
+
+func (n *Notifier) worker(ctx context.Context, workerID int, group *sync.WaitGroup) {
+	for {
+		health.Beat("notifier", workerID)
+		if n.poll(workerID) {
+			select {
+			case <-ctx.Done():
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.WithFields(log.Fields{
+				"event":  "ctx_canceled",
+				"worker": workerID,
+			}).Info("exit goroutine")
+			health.Forget("notifier", workerID)
+			group.Done()
+			return
+		case <-time.After(n.Interval):
+		}
+	}
+}
+
+// Run starts notifier's workers, returned pool can be resized at runtime
+func (n *Notifier) Run(ctx context.Context, group *sync.WaitGroup) *pool.Pool {
+	log.WithFields(log.Fields{
+		"event": "start_notifier",
+	}).Info("starting ", n.Workers, " notifier workers")
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		n.worker(ctx, workerID, group)
+	})
+	workers.Resize(n.Workers)
+	return workers
+}
@@ -0,0 +1,54 @@
+package resulter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+func TestNotifierPost(t *testing.T) {
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/internal", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	tests := []struct {
+		name    string
+		origins []string
+		url     string
+		status  int
+		wantErr bool
+	}{
+		{name: "allowed origin", origins: []string{server.URL}, url: server.URL + "/done", status: http.StatusOK},
+		{name: "no origins", url: server.URL + "/done", wantErr: true},
+		{name: "other origin", origins: []string{"https://objects"}, url: server.URL + "/done", wantErr: true},
+		{name: "other scheme", origins: []string{"https://" + server.Listener.Addr().String()}, url: server.URL + "/done", wantErr: true},
+		{name: "redirect isn't followed", origins: []string{server.URL}, url: server.URL + "/redirect", status: http.StatusFound, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hits = map[string]int{}
+			appCfg := &config.AppConfig{}
+			appCfg.Resulter.Callbacks.Timeout = 1
+			appCfg.Resulter.Callbacks.Origins = test.origins
+			notifier := NewNotifier(appCfg, nil)
+			delivery := &storage.Delivery{ID: 1, TaskID: 1, Callback: storage.Callback{URL: test.url}}
+			status, err := notifier.post(delivery, `{"jsonrpc":"2.0","id":"1"}`)
+			if (err != nil) != test.wantErr || status != test.status {
+				t.Errorf("status = %d, error = %v, want %d and error %v", status, err, test.status, test.wantErr)
+			}
+			if hits["/internal"] > 0 {
+				t.Error("redirect is followed")
+			}
+			if test.status == 0 && len(hits) > 0 {
+				t.Errorf("callback of not allowed origin is called: %v", hits)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package resulter
+
+import (
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/config"
+)
+
+// Role - saves task results to storage
+func Role() app.Role {
+	return app.Role{
+		Name: "resulter",
+		Start: func(rt *app.Runtime) (func(*config.AppConfig), error) {
+			appCfg := rt.Config()
+			repo, err := rt.Repository()
+			if err != nil {
+				return nil, err
+			}
+			cfg := &Config{
+				Queue:      rt.Queue(appCfg.Resulter.Queuesrc),
+				DeadLetter: rt.DeadLetter(),
+				Repository: repo,
+				Workers:    appCfg.Resulter.Workers,
+			}
+			workers := Run(rt.Context(), cfg, rt.Group())
+			notifiers := NewNotifier(appCfg, repo).Run(rt.Context(), rt.Group())
+			return func(current *config.AppConfig) {
+				workers.Resize(current.Resulter.Workers)
+				notifiers.Resize(current.Resulter.Callbacks.Workers)
+			}, nil
+		},
+	}
+}
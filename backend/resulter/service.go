@@ -2,28 +2,149 @@ package resulter
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"sync"
 
 	log "github.com/freundallein/scheduler/backend/chassis/logging"
 
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/pool"
 	"github.com/freundallein/scheduler/backend/chassis/protocol"
 	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/freundallein/scheduler/backend/chassis/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config ...
 type Config struct {
 	Queue      queue.Client
+	DeadLetter queue.Client
 	Repository storage.TaskRepository
 	Workers    int
 }
 
-func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
+// handle saves task result
+func handle(ctx context.Context, cfg *Config, workerID int, msg *queue.RecvMessage) {
 	cli := cfg.Queue
 	repo := cfg.Repository
 
+	response := protocol.Response{}
+	err := response.FromJSON(msg.Body)
+	err = fault.Inject("resulter.decode", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "received_broken_message",
+			"worker": workerID,
+		}).Info(err)
+		return
+	}
+	attempt := response.Attempt
+	log.WithFields(log.Fields{
+		"event":   "receive_result",
+		"worker":  workerID,
+		"taskID":  response.ID,
+		"attempt": attempt,
+	}).Info("receive results for task")
+
+	// v0 responses carry task's ID as response's ID only
+	id := response.TaskID
+	if id == "" {
+		id = response.ID
+	}
+	taskID, err := strconv.Atoi(id)
+	err = fault.Inject("resulter.parse_id", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "received_broken_task_id",
+			"worker": workerID,
+			"taskID": response.ID,
+		}).Error(err)
+		return
+	}
+	_, span := tracing.Start(
+		tracing.Extract(ctx, response.Trace),
+		"result",
+		trace.SpanKindConsumer,
+		attribute.Int("taskID", taskID),
+		attribute.Bool("error", len(response.Error) > 0),
+	)
+	followUp, err := response.FollowUp()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "broken_follow_up",
+			"worker":  workerID,
+			"taskID":  response.ID,
+			"attempt": attempt,
+		}).Error(err)
+	}
+	task := &storage.Task{
+		ID:        taskID,
+		Result:    response.Result,
+		Error:     response.Error,
+		Attempts:  attempt,
+		Permanent: response.Permanent(),
+		FollowUp:  followUp,
+	}
+	err = repo.SetTaskResult(task)
+	err = fault.Inject("resulter.save", err)
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	span.End()
+	if errors.Is(err, storage.ErrStaleResult) {
+		// Task was repaired by supervisor or already has a result
+		staleResults.Inc()
+		log.WithFields(log.Fields{
+			"event":   "stale_result",
+			"worker":  workerID,
+			"taskID":  response.ID,
+			"attempt": attempt,
+		}).Warn(err)
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "result_error",
+			"worker":  workerID,
+			"taskID":  response.ID,
+			"attempt": attempt,
+		}).Error(err)
+	} else {
+		if task.FollowUp != nil {
+			followUps.Inc()
+		}
+		if len(task.Error) == 0 {
+			resultsApplied.WithLabelValues("success").Inc()
+		} else if task.Permanent {
+			resultsApplied.WithLabelValues("permanent_error").Inc()
+		} else {
+			resultsApplied.WithLabelValues("error").Inc()
+		}
+		log.WithFields(log.Fields{
+			"event":   "result_to_storage",
+			"worker":  workerID,
+			"taskID":  response.ID,
+			"attempt": attempt,
+		}).Info("save result to storage")
+	}
+	err = cli.Acknowledge(msg)
+	err = fault.Inject("resulter.ack", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "ack_message_failed",
+			"worker":  workerID,
+			"taskID":  response.ID,
+			"attempt": attempt,
+		}).Error(err)
+	}
+}
+
+func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
+	cli := cfg.Queue
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -31,84 +152,38 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				"event":  "ctx_canceled",
 				"worker": workerID,
 			}).Info("exit goroutine")
+			health.Forget("resulter", workerID)
 			group.Done()
 			return
 		default:
+			health.Beat("resulter", workerID)
 			msg, err := cli.ReceiveMessage()
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "receive_failed",
-					"worker": workerID,
-				}).Error(err)
+			err = fault.Inject("resulter.receive", err)
+			if errors.Is(err, queue.ErrNoMessage) {
 				continue
 			}
-			response := protocol.Response{}
-			err = response.FromJSON(msg.Body)
-			err = monkey.RandomizeError(err)
 			if err != nil {
 				log.WithFields(log.Fields{
-					"event":  "received_broken_message",
-					"worker": workerID,
-				}).Info(err)
-				continue
-			}
-			log.WithFields(log.Fields{
-				"event":  "receive_result",
-				"worker": workerID,
-			}).Info("receive results for task")
-
-			taskID, err := strconv.Atoi(response.ID)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "received_broken_task_id",
-					"worker": workerID,
-					"taskID": response.ID,
-				}).Error(err)
-				continue
-			}
-			task := &storage.Task{
-				ID:     taskID,
-				Result: response.Result,
-				Error:  response.Error,
-			}
-			err = repo.SetTaskResult(task)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "result_error",
-					"worker": workerID,
-					"taskID": response.ID,
-				}).Error(err)
-			} else {
-				log.WithFields(log.Fields{
-					"event":  "result_to_storage",
-					"worker": workerID,
-					"taskID": response.ID,
-				}).Info("save result to storage")
-			}
-			err = cli.Acknowledge(msg)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "ack_message_failed",
+					"event":  "receive_failed",
 					"worker": workerID,
-					"taskID": response.ID,
 				}).Error(err)
 				continue
 			}
+			recovery.Message("resulter", cli, cfg.DeadLetter, msg, func() {
+				handle(ctx, cfg, workerID, msg)
+			})
 		}
 	}
 }
 
-// Run ...
-func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+// Run starts workers, returned pool can be resized at runtime
+func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) *pool.Pool {
 	log.WithFields(log.Fields{
 		"event": "start_service",
 	}).Info("starting ", cfg.Workers, " workers")
-	for wrk := 1; wrk <= cfg.Workers; wrk++ {
-		group.Add(1)
-		go worker(ctx, cfg, wrk, group)
-	}
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		worker(ctx, cfg, workerID, group)
+	})
+	workers.Resize(cfg.Workers)
+	return workers
 }
@@ -0,0 +1,21 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	tasksAcquired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "scheduler",
+		Name:      "tasks_acquired_total",
+		Help:      "Tasks acquired from storage.",
+	}, []string{"action"})
+	sendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "scheduler",
+		Name:      "send_failures_total",
+		Help:      "Acquired tasks that failed to reach outbound queue.",
+	}, []string{"action"})
+)
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/config"
+)
+
+// Role - sends scheduled tasks to workers
+func Role() app.Role {
+	return app.Role{
+		Name: "scheduler",
+		Start: func(rt *app.Runtime) (func(*config.AppConfig), error) {
+			appCfg := rt.Config()
+			repo, err := rt.Repository()
+			if err != nil {
+				return nil, err
+			}
+			cfg := &Config{
+				Queue:      rt.Queue(appCfg.Scheduler.Queuedst),
+				Repository: repo,
+				Workers:    appCfg.Scheduler.Workers,
+				Deadline:   time.Duration(appCfg.Supervisor.StaleTimeout) * time.Second,
+				Version:    appCfg.Protocol.Version,
+			}
+			workers := Run(rt.Context(), cfg, rt.Group())
+			return func(current *config.AppConfig) {
+				workers.Resize(current.Scheduler.Workers)
+				cfg.SetDeadline(time.Duration(current.Supervisor.StaleTimeout) * time.Second)
+			}, nil
+		},
+	}
+}
@@ -2,16 +2,23 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"sync"
 	"time"
 
 	log "github.com/freundallein/scheduler/backend/chassis/logging"
 
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/pool"
 	"github.com/freundallein/scheduler/backend/chassis/protocol"
 	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/freundallein/scheduler/backend/chassis/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config ...
@@ -19,10 +26,130 @@ type Config struct {
 	Queue      queue.Client
 	Repository storage.TaskRepository
 	Workers    int
+	// Deadline of attempts, supervisor repairs them after it, zero means no deadline.
+	// It must be changed with SetDeadline after start.
+	Deadline time.Duration
+	// Version of sent requests, protocol.V0 or protocol.V1
+	Version int
+
+	mu sync.RWMutex
 }
 
-func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
+// SetDeadline applies supervisor's reloaded stale timeout to attempts sent after it
+func (cfg *Config) SetDeadline(deadline time.Duration) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.Deadline = deadline
+}
+
+func (cfg *Config) deadline() time.Duration {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.Deadline
+}
+
+// fail reports panic as task's error, so the task is retried or stopped like after worker's error
+func fail(cfg *Config, workerID int, task *storage.Task, err error) {
+	var p *recovery.Panic
+	digest := ""
+	if errors.As(err, &p) {
+		digest = p.Digest
+	}
+	result := &storage.Task{
+		ID: task.ID,
+		Error: protocol.NewPayload(map[string]string{
+			"code":    protocol.CodeInternal,
+			"message": err.Error(),
+			"stack":   digest,
+		}),
+		Attempts: task.Attempts,
+	}
+	err = cfg.Repository.SetTaskResult(result)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "task_fail_failed",
+			"worker":  workerID,
+			"taskID":  task.ID,
+			"attempt": task.Attempts,
+		}).Error(err)
+	}
+}
+
+// schedule sends acquired task to workers
+func schedule(ctx context.Context, cfg *Config, workerID int, task *storage.Task) {
 	cli := cfg.Queue
+
+	tasksAcquired.WithLabelValues(string(task.Action)).Inc()
+	log.WithFields(log.Fields{
+		"event":    "task_acquire",
+		"worker":   workerID,
+		"taskID":   task.ID,
+		"action":   task.Action,
+		"attempt":  task.Attempts,
+		"objectID": task.Payload.Get("objectID"),
+	}).Info("acquire task")
+	action := string(task.Action)
+	// Every attempt is a separate span under the submit span
+	spanCtx, span := tracing.Start(
+		tracing.Extract(ctx, task.Trace),
+		"schedule "+action,
+		trace.SpanKindProducer,
+		attribute.Int("taskID", task.ID),
+		attribute.String("action", action),
+		attribute.Int("attempt", task.Attempts),
+	)
+	message := protocol.Request{
+		Version: cfg.Version,
+		Method:  action,
+		Params:  task.Payload,
+		ID:      strconv.Itoa(task.ID),
+		TaskID:  strconv.Itoa(task.ID),
+		Attempt: task.Attempts,
+		Trace:   tracing.Inject(spanCtx),
+	}
+	if deadline := cfg.deadline(); deadline > 0 {
+		message.Deadline = time.Now().Add(deadline)
+	}
+	jsonMsg, err := message.JSON()
+	err = fault.Inject("scheduler.encode", err)
+	if err != nil {
+		tracing.Fail(span, err)
+		span.End()
+		log.WithFields(log.Fields{
+			"event":  "request_serialize_failed",
+			"worker": workerID,
+			"taskID": task.ID,
+		}).Error(err)
+		return
+	}
+	err = fault.Send("scheduler.send", func() error {
+		return cli.SendMessage(jsonMsg)
+	})
+	if err != nil {
+		tracing.Fail(span, err)
+		span.End()
+		sendFailures.WithLabelValues(string(task.Action)).Inc()
+		log.WithFields(log.Fields{
+			"event":   "request_send_failed",
+			"worker":  workerID,
+			"taskID":  task.ID,
+			"action":  task.Action,
+			"attempt": task.Attempts,
+		}).Error(err)
+		return
+	}
+	span.End()
+	log.WithFields(log.Fields{
+		"event":    "send_task",
+		"worker":   workerID,
+		"taskID":   task.ID,
+		"action":   task.Action,
+		"attempt":  task.Attempts,
+		"objectID": task.Payload.Get("objectID"),
+	}).Info("send task to workers")
+}
+
+func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
 	repo := cfg.Repository
 
 	for {
@@ -32,13 +159,15 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				"event":  "ctx_canceled",
 				"worker": workerID,
 			}).Info("exit goroutine")
+			health.Forget("scheduler", workerID)
 			group.Done()
 			return
 		default:
+			health.Beat("scheduler", workerID)
 			task, err := repo.SelectTask()
-			err = monkey.RandomizeError(err)
+			err = fault.Inject("scheduler.select", err)
 			if err != nil {
-				if err.Error() == "no rows in result set" {
+				if errors.Is(err, storage.ErrNoTask) {
 					log.WithFields(log.Fields{
 						"event":  "select_task_failed",
 						"worker": workerID,
@@ -52,61 +181,24 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				}
 				continue
 			}
-			log.WithFields(log.Fields{
-				"event":    "task_acquire",
-				"worker":   workerID,
-				"taskID":   task.ID,
-				"action":   task.Action,
-				"objectID": task.Payload["objectID"],
-			}).Info("acquire task")
-			var action string
-			switch task.Action {
-			case storage.EXPORT:
-				action = string(storage.EXPORT)
-			default:
-				action = string(storage.DUMMY)
-			}
-			message := protocol.Request{
-				Method: action,
-				Params: task.Payload,
-				ID:     strconv.Itoa(task.ID),
-			}
-			jsonMsg, err := message.JSON()
-			err = monkey.RandomizeError(err)
+			err = recovery.Do("scheduler", func() {
+				schedule(ctx, cfg, workerID, task)
+			})
 			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "request_serialize_failed",
-					"worker": workerID,
-				}).Error(err)
-				continue
+				fail(cfg, workerID, task, err)
 			}
-			err = cli.SendMessage(jsonMsg)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "request_send_failed",
-					"worker": workerID,
-				}).Error(err)
-				continue
-			}
-			log.WithFields(log.Fields{
-				"event":    "send_task",
-				"worker":   workerID,
-				"taskID":   task.ID,
-				"action":   task.Action,
-				"objectID": task.Payload["objectID"],
-			}).Info("send task to workers")
 		}
 	}
 }
 
-// Run ...
-func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+// Run starts workers, returned pool can be resized at runtime
+func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) *pool.Pool {
 	log.WithFields(log.Fields{
 		"event": "start_service",
 	}).Info("starting ", cfg.Workers, " workers")
-	for wrk := 1; wrk <= cfg.Workers; wrk++ {
-		group.Add(1)
-		go worker(ctx, cfg, wrk, group)
-	}
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		worker(ctx, cfg, workerID, group)
+	})
+	workers.Resize(cfg.Workers)
+	return workers
 }
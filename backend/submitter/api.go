@@ -0,0 +1,138 @@
+package submitter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/gorilla/mux"
+)
+
+// maxSubmitBody - bytes of submit accepted by HTTP API
+const maxSubmitBody = 1 << 20
+
+// apiError - body of failed API request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// taskView converts stored task to API's one
+func taskView(task *storage.Task) *protocol.Task {
+	return &protocol.Task{
+		ID:        strconv.Itoa(task.ID),
+		Action:    string(task.Action),
+		State:     string(task.State),
+		Params:    task.Payload,
+		Result:    task.Result,
+		Error:     task.Error,
+		Attempts:  task.Attempts,
+		Priority:  task.Priority,
+		CreatedDt: task.CreatedDt,
+		UpdatedDt: task.UpdatedDt,
+	}
+}
+
+// taskID parses task's ID of URL
+func taskID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// submitHandler stores JSON-RPC submit, the same as in inbound queue, and returns created task's ID
+func submitHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := &protocol.Request{}
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmitBody)).Decode(request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		task, err := submit(r.Context(), cfg, request, "api")
+		var rejected *rejection
+		switch {
+		case errors.As(err, &rejected):
+			writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, storage.ErrDuplicate):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusCreated, map[string]string{"id": strconv.Itoa(task.ID)})
+		}
+	}
+}
+
+// getHandler returns task's state
+func getHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := taskID(w, r)
+		if !ok {
+			return
+		}
+		task, err := cfg.Repository.GetTask(id)
+		if errors.Is(err, storage.ErrNoSuchTask) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, taskView(task))
+	}
+}
+
+// cancelHandler cancels task which isn't finished
+func cancelHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := taskID(w, r)
+		if !ok {
+			return
+		}
+		err := cfg.Repository.CancelTask(id)
+		switch {
+		case errors.Is(err, storage.ErrNoSuchTask):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, storage.ErrTaskFinished):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			log.WithFields(log.Fields{
+				"event":  "task_canceled",
+				"worker": "api",
+				"taskID": id,
+			}).Info("cancel task")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// Routes registers HTTP API of tasks:
+// POST /api/v1/tasks, GET /api/v1/tasks/{id} and DELETE /api/v1/tasks/{id}
+func Routes(router *mux.Router, cfg *Config, token string) {
+	api := router.PathPrefix("/api/v1/tasks").Subrouter()
+	api.Handle("", app.Authorize(token, submitHandler(cfg))).Methods(http.MethodPost)
+	api.Handle("/{id}", app.Authorize(token, getHandler(cfg))).Methods(http.MethodGet)
+	api.Handle("/{id}", app.Authorize(token, cancelHandler(cfg))).Methods(http.MethodDelete)
+}
@@ -0,0 +1,21 @@
+package submitter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	tasksSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "submitter",
+		Name:      "tasks_submitted_total",
+		Help:      "Tasks persisted to storage.",
+	}, []string{"action"})
+	duplicatesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "submitter",
+		Name:      "duplicates_rejected_total",
+		Help:      "Tasks rejected by idempotency key.",
+	}, []string{"action"})
+)
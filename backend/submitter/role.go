@@ -0,0 +1,46 @@
+package submitter
+
+import (
+	"fmt"
+
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+// Role - reads tasks from inbound queue and stores them
+func Role() app.Role {
+	return app.Role{
+		Name: "submitter",
+		Start: func(rt *app.Runtime) (func(*config.AppConfig), error) {
+			appCfg := rt.Config()
+			repo, err := rt.Repository()
+			if err != nil {
+				return nil, err
+			}
+			keyFields := map[storage.Action][]string{}
+			for action, fields := range appCfg.Submitter.Idempotency.Fields {
+				known, err := storage.ParseAction(action)
+				if err != nil {
+					return nil, fmt.Errorf("submitter.idempotency.fields: %w", err)
+				}
+				keyFields[known] = fields
+			}
+			cfg := &Config{
+				Queue:       rt.Queue(appCfg.Submitter.Queuesrc),
+				DeadLetter:  rt.DeadLetter(),
+				Repository:  repo,
+				Workers:     appCfg.Submitter.Workers,
+				DedupWindow: appCfg.Submitter.Idempotency.Window,
+				KeyFields:   keyFields,
+			}
+			if appCfg.Submitter.API.Enabled {
+				Routes(rt.Router(), cfg, appCfg.Submitter.API.Token)
+			}
+			workers := Run(rt.Context(), cfg, rt.Group())
+			return func(current *config.AppConfig) {
+				workers.Resize(current.Submitter.Workers)
+			}, nil
+		},
+	}
+}
@@ -2,27 +2,259 @@ package submitter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	log "github.com/freundallein/scheduler/backend/chassis/logging"
 
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/pool"
 	"github.com/freundallein/scheduler/backend/chassis/protocol"
 	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/freundallein/scheduler/backend/chassis/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config ...
 type Config struct {
-	Queue      queue.Client
-	Repository storage.TaskRepository
-	Workers    int
+	Queue       queue.Client
+	DeadLetter  queue.Client
+	Repository  storage.TaskRepository
+	Workers     int
+	DedupWindow int
+	KeyFields   map[storage.Action][]string
+}
+
+// idempotencyKey returns caller-supplied key or builds one from action's key fields.
+// Empty key means that the task is not deduplicated.
+func idempotencyKey(request *protocol.Request, fields []string) (string, error) {
+	if request.IdempotencyKey != "" {
+		return request.IdempotencyKey, nil
+	}
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !request.Params.Has(field) {
+			return "", fmt.Errorf("no %s supported", field)
+		}
+		parts = append(parts, field+"="+request.Params.Get(field))
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// callback validates submit's callback or reply-to queue, task without callback gets empty one
+func callback(request *protocol.Request) (storage.Callback, error) {
+	if request.Callback == nil && request.ReplyTo == "" {
+		return storage.Callback{}, nil
+	}
+	result := storage.Callback{Queue: request.ReplyTo, CorrelationID: request.ID}
+	if request.Callback != nil {
+		if request.ReplyTo != "" {
+			return result, errors.New("submit must have either callback or replyTo")
+		}
+		result.URL = request.Callback.URL
+		result.Queue = request.Callback.Queue
+	}
+	if result.URL != "" && result.Queue != "" {
+		return result, errors.New("callback must have either url or queue")
+	}
+	if result.Empty() {
+		return result, errors.New("callback must have url or queue")
+	}
+	if result.URL != "" {
+		target, err := url.Parse(result.URL)
+		if err != nil {
+			return result, err
+		}
+		if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return result, fmt.Errorf("callback url must be absolute http(s) url, got %q", result.URL)
+		}
+	}
+	return result, nil
+}
+
+// reject parks message in dead-letter queue and removes it from inbound queue,
+// message stays in inbound queue if dead-letter queue is unavailable or not configured
+func reject(cli queue.Client, deadLetter queue.Client, msg *queue.RecvMessage, reason string, cause error, workerID int) {
+	err := queue.SendDeadLetter(deadLetter, "submitter", reason, msg, cause)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "dead_letter_failed",
+			"worker": workerID,
+		}).Error(err)
+		return
+	}
+	err = cli.Acknowledge(msg)
+	err = fault.Inject("submitter.ack", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "ack_message_failed",
+			"worker": workerID,
+		}).Error(err)
+	}
+}
+
+// rejection - submit which can't be stored, reason is used in dead-letter queue
+type rejection struct {
+	reason string
+	err    error
+}
+
+func (r *rejection) Error() string {
+	return r.err.Error()
+}
+
+func (r *rejection) Unwrap() error {
+	return r.err
+}
+
+// submit validates request and stores its task, duplicated task is returned with storage.ErrDuplicate
+func submit(ctx context.Context, cfg *Config, request *protocol.Request, workerID interface{}) (*storage.Task, error) {
+	repo := cfg.Repository
+
+	action, err := storage.ParseSubmit(request.Method)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "unknown_action",
+			"worker": workerID,
+		}).Error(err)
+		return nil, &rejection{reason: "unknown_action", err: err}
+	}
+	taskCallback, err := callback(request)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "invalid_callback",
+			"worker": workerID,
+			"action": action,
+		}).Error(err)
+		return nil, &rejection{reason: "invalid_callback", err: err}
+	}
+	if request.Delay < 0 {
+		err = fmt.Errorf("delay must be at least 0, got %d", request.Delay)
+		log.WithFields(log.Fields{
+			"event":  "invalid_delay",
+			"worker": workerID,
+			"action": action,
+		}).Error(err)
+		return nil, &rejection{reason: "invalid_delay", err: err}
+	}
+	key, err := idempotencyKey(request, cfg.KeyFields[action])
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "unsupported_message",
+			"worker": workerID,
+			"action": action,
+		}).Error(err)
+		return nil, &rejection{reason: "unsupported_message", err: err}
+	}
+	log.WithFields(log.Fields{
+		"event":  "receive_message",
+		"worker": workerID,
+		"action": action,
+		"key":    key,
+		"params": request.Params,
+	}).Info("receive submit")
+	spanCtx, span := tracing.Start(
+		tracing.Extract(ctx, request.Trace),
+		"submit "+string(action),
+		trace.SpanKindConsumer,
+		attribute.String("action", string(action)),
+		attribute.String("key", key),
+	)
+	defer span.End()
+	task := &storage.Task{
+		Action:    action,
+		Payload:   request.Params,
+		CreatedDt: time.Now(),
+		UpdatedDt: time.Now(),
+		State:     storage.SCHEDULED,
+		Result:    protocol.Payload{},
+		Attempts:  0,
+		Trace:     tracing.Inject(spanCtx),
+		Callback:  taskCallback,
+		Priority:  request.Priority,
+		Delay:     request.Delay,
+
+		IdempotencyKey: key,
+		DedupWindow:    cfg.DedupWindow,
+	}
+	err = repo.Enqueue(task)
+	err = fault.Inject("submitter.enqueue", err)
+	if errors.Is(err, storage.ErrDuplicate) {
+		span.AddEvent("duplicated_task")
+		duplicatesRejected.WithLabelValues(string(action)).Inc()
+		log.WithFields(log.Fields{
+			"event":  "duplicated_task",
+			"worker": workerID,
+			"action": action,
+			"key":    key,
+		}).Warn("receive duplicated task")
+		return task, err
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		log.WithFields(log.Fields{
+			"event":  "submit_failed",
+			"worker": workerID,
+			"action": action,
+			"key":    key,
+		}).Error(err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("taskID", task.ID))
+	tasksSubmitted.WithLabelValues(string(action)).Inc()
+	log.WithFields(log.Fields{
+		"event":  "submit_to_db",
+		"worker": workerID,
+		"action": action,
+		"key":    key,
+		"taskID": task.ID,
+	}).Info("submit task to storage")
+	return task, nil
+}
+
+// handle stores submitted task
+func handle(ctx context.Context, cfg *Config, workerID int, msg *queue.RecvMessage) {
+	cli := cfg.Queue
+
+	request := protocol.Request{}
+	err := request.FromJSON(msg.Body)
+	err = fault.Inject("submitter.decode", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "received_broken_message",
+			"worker": workerID,
+		}).Error(err)
+		return
+	}
+	_, err = submit(ctx, cfg, &request, workerID)
+	var rejected *rejection
+	if errors.As(err, &rejected) {
+		reject(cli, cfg.DeadLetter, msg, rejected.reason, rejected.err, workerID)
+		return
+	}
+	if err != nil && !errors.Is(err, storage.ErrDuplicate) {
+		return
+	}
+	err = cli.Acknowledge(msg)
+	err = fault.Inject("submitter.ack", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "ack_message_failed",
+			"worker": workerID,
+		}).Error(err)
+	}
 }
 
 func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
 	cli := cfg.Queue
-	repo := cfg.Repository
 
 	for {
 		select {
@@ -31,106 +263,38 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				"event":  "ctx_canceled",
 				"worker": workerID,
 			}).Info("exit goroutine")
+			health.Forget("submitter", workerID)
 			group.Done()
 			return
 		default:
+			health.Beat("submitter", workerID)
 			msg, err := cli.ReceiveMessage()
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "receive_failed",
-					"worker": workerID,
-				}).Error(err)
+			err = fault.Inject("submitter.receive", err)
+			if errors.Is(err, queue.ErrNoMessage) {
 				continue
 			}
-			request := protocol.Request{}
-			err = request.FromJSON(msg.Body)
-			err = monkey.RandomizeError(err)
 			if err != nil {
 				log.WithFields(log.Fields{
-					"event":  "received_broken_message",
-					"worker": workerID,
-				}).Error(err)
-				continue
-			}
-			if _, ok := request.Params["objectID"]; !ok {
-				log.WithFields(log.Fields{
-					"event":  "unsupported_message",
+					"event":  "receive_failed",
 					"worker": workerID,
-				}).Error("no objectID supported")
-				continue
-			}
-			var action storage.Action
-			switch request.Method {
-			case "submit:export":
-				action = storage.EXPORT
-			default:
-				action = storage.DUMMY
-			}
-			log.WithFields(log.Fields{
-				"event":    "receive_message",
-				"worker":   workerID,
-				"action":   action,
-				"objectID": request.Params["objectID"],
-			}).Info(request)
-			task := &storage.Task{
-				Action:    action,
-				Payload:   request.Params,
-				CreatedDt: time.Now(),
-				UpdatedDt: time.Now(),
-				State:     storage.SCHEDULED,
-				Result:    map[string]string{},
-				Attempts:  0,
-			}
-			err = repo.Enqueue(task)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				if err.Error() != "duplicated task" {
-					log.WithFields(log.Fields{
-						"event":    "submit_failed",
-						"worker":   workerID,
-						"action":   action,
-						"objectID": request.Params["objectID"],
-					}).Error(err)
-					continue
-				}
-				log.WithFields(log.Fields{
-					"event":    "duplicated_task",
-					"worker":   workerID,
-					"action":   action,
-					"objectID": request.Params["objectID"],
-				}).Warn("receive duplicated task")
-			} else {
-				log.WithFields(log.Fields{
-					"event":    "submit_to_db",
-					"worker":   workerID,
-					"action":   action,
-					"objectID": request.Params["objectID"],
-				}).Info("submit task to storage")
-			}
-
-			err = cli.Acknowledge(msg)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":    "ack_message_failed",
-					"worker":   workerID,
-					"action":   action,
-					"objectID": request.Params["objectID"],
 				}).Error(err)
 				continue
 			}
+			recovery.Message("submitter", cli, cfg.DeadLetter, msg, func() {
+				handle(ctx, cfg, workerID, msg)
+			})
 		}
 	}
 }
 
-// Run ...
-func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+// Run starts workers, returned pool can be resized at runtime
+func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) *pool.Pool {
 	log.WithFields(log.Fields{
 		"event": "start_service",
 	}).Info("starting ", cfg.Workers, " workers")
-	for wrk := 1; wrk <= cfg.Workers; wrk++ {
-		group.Add(1)
-		go worker(ctx, cfg, wrk, group)
-	}
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		worker(ctx, cfg, workerID, group)
+	})
+	workers.Resize(cfg.Workers)
+	return workers
 }
@@ -0,0 +1,41 @@
+package submitter
+
+import (
+	"testing"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	params := protocol.NewPayload(map[string]string{"objectID": "23", "format": "csv"})
+	if err := params.Set("ids", []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		key     string
+		fields  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "no fields", want: ""},
+		{name: "explicit key wins", key: "export-23", fields: []string{"objectID"}, want: "export-23"},
+		{name: "one field", fields: []string{"objectID"}, want: "objectID=23"},
+		{name: "fields in configured order", fields: []string{"format", "objectID"}, want: "format=csv;objectID=23"},
+		{name: "JSON value", fields: []string{"ids"}, want: "ids=[1,2]"},
+		{name: "missing field", fields: []string{"objectID", "owner"}, wantErr: true},
+		{name: "explicit key without fields", key: "export-23", fields: []string{"owner"}, want: "export-23"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := &protocol.Request{ID: "1", Method: "export", IdempotencyKey: test.key, Params: params}
+			got, err := idempotencyKey(request, test.fields)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("key = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package supervisor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	staleTasksRepaired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "supervisor",
+		Name:      "stale_tasks_repaired_total",
+		Help:      "Stale ACQUIRED tasks moved to ERROR/CRITICAL_ERROR.",
+	})
+	rowsCleaned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "supervisor",
+		Name:      "rows_cleaned_total",
+		Help:      "Rows deleted by db cleaner.",
+	}, []string{"table"})
+	tasksByState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scheduler",
+		Subsystem: "storage",
+		Name:      "tasks",
+		Help:      "Tasks count by state.",
+	}, []string{"state"})
+	oldestScheduledAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scheduler",
+		Subsystem: "storage",
+		Name:      "oldest_scheduled_age_seconds",
+		Help:      "Age of the oldest SCHEDULED task.",
+	})
+)
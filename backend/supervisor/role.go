@@ -0,0 +1,41 @@
+package supervisor
+
+import (
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/config"
+)
+
+func settings(appCfg *config.AppConfig) Settings {
+	return Settings{
+		StaleTimeout:    appCfg.Supervisor.StaleTimeout,
+		RepairBatchSize: appCfg.Supervisor.RepairBatchSize,
+		Expiration:      appCfg.Supervisor.Expiration,
+		Interval:        time.Duration(appCfg.Supervisor.Interval) * time.Second,
+	}
+}
+
+// Role - repairs stale tasks and cleans storage
+func Role() app.Role {
+	return app.Role{
+		Name: "supervisor",
+		Start: func(rt *app.Runtime) (func(*config.AppConfig), error) {
+			appCfg := rt.Config()
+			repo, err := rt.Repository()
+			if err != nil {
+				return nil, err
+			}
+			cfg := &Config{
+				Repository: repo,
+				Workers:    appCfg.Supervisor.Workers,
+				Settings:   settings(appCfg),
+			}
+			workers := Run(rt.Context(), cfg, rt.Group())
+			return func(current *config.AppConfig) {
+				cfg.Reload(settings(current))
+				workers.Resize(current.Supervisor.Workers)
+			}, nil
+		},
+	}
+}
@@ -7,21 +7,45 @@ import (
 
 	log "github.com/freundallein/scheduler/backend/chassis/logging"
 
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/pool"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
 )
 
-// Config ...
-type Config struct {
-	Repository      storage.TaskRepository
-	Workers         int
+// Settings - supervisor's parameters, which can be changed at runtime
+type Settings struct {
 	StaleTimeout    int
 	RepairBatchSize int
 	Expiration      int
+	Interval        time.Duration
+}
+
+// Config ...
+type Config struct {
+	Repository storage.TaskRepository
+	Workers    int
+	// Settings must be changed with Reload after start
+	Settings Settings
+
+	mu sync.RWMutex
+}
+
+// Reload applies new settings to running goroutines
+func (cfg *Config) Reload(settings Settings) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.Settings = settings
+}
+
+func (cfg *Config) current() Settings {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.Settings
 }
 
 func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
-	group.Add(1)
 	repo := cfg.Repository
 	for {
 		select {
@@ -30,21 +54,28 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				"event":  "ctx_canceled",
 				"worker": workerID,
 			}).Info("exit goroutine")
+			health.Forget("supervisor", workerID)
 			group.Done()
 			return
-		case <-time.After(time.Second * 5):
-			repaired, err := repo.RepairStaleTasks(cfg.StaleTimeout, cfg.RepairBatchSize)
-			err = monkey.RandomizeError(err)
-			if err != nil {
+		case <-time.After(cfg.current().Interval):
+			// The next beat comes after loop's work and interval, both may be long
+			health.BeatWithin("supervisor", workerID, cfg.current().Interval+health.StaleAfter)
+			recovery.Do("supervisor", func() {
+				settings := cfg.current()
+				repaired, err := repo.RepairStaleTasks(settings.StaleTimeout, settings.RepairBatchSize)
+				err = fault.Inject("supervisor.repair", err)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"event":  "stale_task_repair_failed",
+						"worker": workerID,
+					}).Error(err)
+				}
+				staleTasksRepaired.Add(float64(repaired))
 				log.WithFields(log.Fields{
-					"event":  "stale_task_repair_failed",
+					"event":  "stale_task_repair",
 					"worker": workerID,
-				}).Error(err)
-			}
-			log.WithFields(log.Fields{
-				"event":  "stale_task_repair",
-				"worker": workerID,
-			}).Info("select and repair stale tasks:", repaired)
+				}).Info("select and repair stale tasks:", repaired)
+			})
 		}
 	}
 }
@@ -52,8 +83,7 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 func dbCleaner(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
 	log.WithFields(log.Fields{
 		"event": "start_db_cleaner",
-	}).Info("starting db cleaner with ", cfg.Expiration, "s expiration time")
-	group.Add(1)
+	}).Info("starting db cleaner with ", cfg.current().Expiration, "s expiration time")
 	repo := cfg.Repository
 	for {
 		select {
@@ -62,32 +92,111 @@ func dbCleaner(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
 				"event":  "ctx_canceled",
 				"worker": "db_cleaner",
 			}).Info("exit goroutine")
+			health.Forget("supervisor", "db_cleaner")
 			group.Done()
 			return
-		case <-time.After(time.Second * 5):
-			cleaned, err := repo.CleanOldTasks(cfg.Expiration)
-			err = monkey.RandomizeError(err)
-			if err != nil {
+		case <-time.After(cfg.current().Interval):
+			// The next beat comes after loop's work and interval, both may be long
+			health.BeatWithin("supervisor", "db_cleaner", cfg.current().Interval+health.StaleAfter)
+			recovery.Do("supervisor", func() {
+				cleaned, err := repo.CleanOldTasks(cfg.current().Expiration)
+				err = fault.Inject("supervisor.clean", err)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"event":  "clean_table_failed",
+						"worker": "db_cleaner",
+					}).Error(err)
+				}
+				rowsCleaned.WithLabelValues("t_scheduler").Add(float64(cleaned))
+				log.WithFields(log.Fields{
+					"event":  "clean_table",
+					"worker": "db_cleaner",
+				}).Info("cleaned rows:", cleaned)
+				expired, err := repo.CleanExpiredKeys()
+				err = fault.Inject("supervisor.clean_keys", err)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"event":  "clean_keys_failed",
+						"worker": "db_cleaner",
+					}).Error(err)
+				}
+				rowsCleaned.WithLabelValues("t_idempotency").Add(float64(expired))
+				log.WithFields(log.Fields{
+					"event":  "clean_keys",
+					"worker": "db_cleaner",
+				}).Info("cleaned idempotency keys:", expired)
+				deliveries, err := repo.CleanDeliveries(cfg.current().Expiration)
+				err = fault.Inject("supervisor.clean_callbacks", err)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"event":  "clean_callbacks_failed",
+						"worker": "db_cleaner",
+					}).Error(err)
+				}
+				rowsCleaned.WithLabelValues("t_callback").Add(float64(deliveries))
 				log.WithFields(log.Fields{
-					"event":  "clean_table_failed",
+					"event":  "clean_callbacks",
 					"worker": "db_cleaner",
-				}).Error(err)
-			}
+				}).Info("cleaned callback deliveries:", deliveries)
+			})
+		}
+	}
+}
+
+func statsCollector(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+	repo := cfg.Repository
+	states := []storage.State{
+		storage.SCHEDULED,
+		storage.ACQUIRED,
+		storage.SUCCESS,
+		storage.ERROR,
+		storage.CRITICAL_ERROR,
+		storage.CANCELED,
+	}
+	for {
+		select {
+		case <-ctx.Done():
 			log.WithFields(log.Fields{
-				"event":  "clean_table",
-				"worker": "db_cleaner",
-			}).Info("cleaned rows:", cleaned)
+				"event":  "ctx_canceled",
+				"worker": "stats_collector",
+			}).Info("exit goroutine")
+			health.Forget("supervisor", "stats_collector")
+			group.Done()
+			return
+		case <-time.After(cfg.current().Interval):
+			// The next beat comes after loop's work and interval, both may be long
+			health.BeatWithin("supervisor", "stats_collector", cfg.current().Interval+health.StaleAfter)
+			recovery.Do("supervisor", func() {
+				stats, err := repo.Stats()
+				err = fault.Inject("supervisor.stats", err)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"event":  "collect_stats_failed",
+						"worker": "stats_collector",
+					}).Error(err)
+					return
+				}
+				for _, state := range states {
+					tasksByState.WithLabelValues(string(state)).Set(float64(stats.States[state]))
+				}
+				oldestScheduledAge.Set(stats.OldestScheduledAge.Seconds())
+			})
 		}
 	}
 }
 
-// Run ...
-func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+// Run starts db cleaner, stats collector and stale task repairers, returned pool of repairers can be resized at runtime
+func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) *pool.Pool {
 	log.WithFields(log.Fields{
 		"event": "start_service",
 	}).Info("starting ", cfg.Workers, " workers")
+	// Added before start, so shutdown's group.Wait doesn't miss them
+	group.Add(2)
 	go dbCleaner(ctx, cfg, group)
-	for wrk := 1; wrk <= cfg.Workers; wrk++ {
-		go worker(ctx, cfg, wrk, group)
-	}
+	go statsCollector(ctx, cfg, group)
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		worker(ctx, cfg, workerID, group)
+	})
+	workers.Resize(cfg.Workers)
+	return workers
 }
@@ -10,8 +10,8 @@ import (
 
 	"math/rand"
 
-	"github.com/freundallein/scheduler/backend/chassis/protocol"
 	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/client"
 	"github.com/jackc/pgx/v4"
 )
 
@@ -33,7 +33,7 @@ func randSeq(n int) string {
 }
 
 func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
-	cli := cfg.QueueDst
+	cli := client.New(client.NewQueue(cfg.QueueDst))
 	conn, err := pgx.Connect(context.Background(), cfg.StorageDSN)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -65,19 +65,7 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				continue
 			}
 
-			message := protocol.Request{
-				Method: "submit:export",
-				Params: map[string]string{"objectID": strconv.Itoa(insertedID)},
-			}
-			jsonMsg, err := message.JSON()
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "serialize_failed",
-					"worker": workerID,
-				}).Error(err)
-				continue
-			}
-			err = cli.SendMessage(jsonMsg)
+			_, err = cli.Submit(ctx, "export", map[string]string{"objectID": strconv.Itoa(insertedID)})
 			if err != nil {
 				log.WithFields(log.Fields{
 					"event":  "send_message_failed",
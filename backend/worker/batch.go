@@ -0,0 +1,269 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+const (
+	defaultBatchSize = 1000
+	// maxParams - postgres limit of params per statement
+	maxParams = 65535
+)
+
+// Params of batch export tasks
+const (
+	paramIDs        = "ids"        // Array or comma-separated string of keys
+	paramFrom       = "from"       // Inclusive range of keys
+	paramTo         = "to"         // ...
+	paramGeneration = "generation" // Number of follow-up, they are limited by storage.TaskMaxRetries
+)
+
+// batch - outcome of a batch export
+type batch struct {
+	exported int64
+	missing  []string // Keys without source rows
+	failed   []string // Keys which weren't inserted
+	reason   string   // Last insert error
+}
+
+func isBatch(request *protocol.Request) bool {
+	return request.Params.Has(paramIDs) || request.Params.Has(paramFrom)
+}
+
+func splitIDs(ids string) []string {
+	list := []string{}
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// batchIDs returns unique keys of `ids` param, a JSON array of strings and numbers or a comma-separated string
+func batchIDs(params protocol.Payload) ([]string, error) {
+	var values []json.RawMessage
+	if json.Unmarshal(params[paramIDs], &values) != nil {
+		return unique(splitIDs(params.Get(paramIDs))), nil
+	}
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		var id string
+		if json.Unmarshal(value, &id) != nil {
+			var number json.Number
+			if err := json.Unmarshal(value, &number); err != nil {
+				return nil, fmt.Errorf("ids must be strings or numbers, got %s", value)
+			}
+			id = number.String()
+		}
+		if id = strings.TrimSpace(id); id != "" {
+			list = append(list, id)
+		}
+	}
+	return unique(list), nil
+}
+
+func unique(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	list := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// placeholders returns `($1, $2), ($3, $4)` for rows of width
+func placeholders(rows int, width int) string {
+	groups := make([]string, 0, rows)
+	for i := 0; i < rows; i++ {
+		group := make([]string, 0, width)
+		for j := 1; j <= width; j++ {
+			group = append(group, "$"+strconv.Itoa(i*width+j))
+		}
+		groups = append(groups, "("+strings.Join(group, ", ")+")")
+	}
+	return strings.Join(groups, ", ")
+}
+
+// query selects rows of batch, the last value of each row is its key as text or as it was requested
+func (job *exportJob) query(ctx context.Context, query string, args ...interface{}) ([][]interface{}, error) {
+	rows, err := job.source.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := [][]interface{}{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
+
+// insert copies rows with one statement, if it fails rows are inserted one by one to find failed ones
+func (job *exportJob) insert(ctx context.Context, rows [][]interface{}, result *batch) {
+	if len(rows) == 0 {
+		return
+	}
+	args := make([]interface{}, 0, len(rows)*job.width)
+	for _, row := range rows {
+		args = append(args, row[:job.width]...)
+	}
+	query := job.insertInto + " values " + placeholders(len(rows), job.width) + job.onConflict
+	tag, err := job.target.Exec(ctx, query, args...)
+	err = fault.Inject("worker.export.insert", err)
+	if err == nil {
+		result.exported += tag.RowsAffected()
+		return
+	}
+	for _, row := range rows {
+		tag, err := job.target.Exec(ctx, job.insertQuery, row[:job.width]...)
+		if err != nil && !storage.IsUniqueViolation(err) {
+			result.failed = append(result.failed, row[job.width].(string))
+			result.reason = err.Error()
+			continue
+		}
+		result.exported += tag.RowsAffected()
+	}
+}
+
+// exportIDs copies listed keys, keys without source rows are reported as missing
+func (job *exportJob) exportIDs(ctx context.Context, ids []string, result *batch) error {
+	for start := 0; start < len(ids); start += job.batchSize {
+		end := start + job.batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+		rows, err := job.query(ctx, job.idsSelect, chunk, job.keyColumn)
+		err = fault.Inject("worker.export.select", err)
+		if err != nil {
+			return err
+		}
+		found := map[string]bool{}
+		for _, row := range rows {
+			found[row[job.width].(string)] = true
+		}
+		for _, id := range chunk {
+			if !found[id] {
+				result.missing = append(result.missing, id)
+			}
+		}
+		job.insert(ctx, rows, result)
+	}
+	return nil
+}
+
+// exportRange copies keys within inclusive range page by page
+func (job *exportJob) exportRange(ctx context.Context, from string, to string, result *batch) error {
+	first := fmt.Sprintf("%s where %s >= $1 and %s <= $2 order by %s limit %d", job.batchSelect, job.key, job.key, job.key, job.batchSize)
+	next := fmt.Sprintf("%s where %s > $1 and %s <= $2 order by %s limit %d", job.batchSelect, job.key, job.key, job.key, job.batchSize)
+	query := first
+	var last interface{} = from
+	for {
+		rows, err := job.query(ctx, query, last, to)
+		err = fault.Inject("worker.export.select", err)
+		if err != nil {
+			return err
+		}
+		job.insert(ctx, rows, result)
+		if len(rows) < job.batchSize {
+			return nil
+		}
+		query = next
+		last = rows[len(rows)-1][job.keyIndex]
+	}
+}
+
+// handleBatch copies `ids` or `from`-`to` range, failed keys are retried with a follow-up task.
+// Missing keys are only reported: they have no source rows, so retries can't copy them.
+func (job *exportJob) handleBatch(request *protocol.Request) *protocol.Response {
+	ctx := request.Context()
+	workerID := WorkerID(ctx)
+	if job.key == "" {
+		return protocol.NewError(request, "3", "batch export needs single key column", true)
+	}
+	result := &batch{}
+	var err error
+	if request.Params.Has(paramIDs) {
+		var ids []string
+		ids, err = batchIDs(request.Params)
+		if err != nil {
+			return protocol.NewError(request, "3", err.Error(), true)
+		}
+		err = job.exportIDs(ctx, ids, result)
+	} else {
+		if !request.Params.Has(paramTo) {
+			return protocol.NewError(request, "3", "no to supported", true)
+		}
+		err = job.exportRange(ctx, request.Params.Get(paramFrom), request.Params.Get(paramTo), result)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "select_batch_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"attempt": request.Attempt,
+		}).Error(err)
+		return protocol.NewError(request, "1", err.Error(), false)
+	}
+	values := map[string]string{
+		"result":   "success",
+		"exported": strconv.FormatInt(result.exported, 10),
+	}
+	if len(result.missing) > 0 {
+		values["missing"] = strings.Join(result.missing, ",")
+	}
+	if len(result.failed) > 0 {
+		values["failed"] = strings.Join(result.failed, ",")
+		values["reason"] = result.reason
+	}
+	if len(result.missing) > 0 || len(result.failed) > 0 {
+		values["result"] = "partial"
+	}
+	response := protocol.NewResult(request, values)
+	log.WithFields(log.Fields{
+		"event":    "batch_processed",
+		"worker":   workerID,
+		"taskID":   request.ID,
+		"attempt":  request.Attempt,
+		"exported": result.exported,
+		"missing":  len(result.missing),
+		"failed":   len(result.failed),
+	}).Info("export batch")
+	if len(result.failed) == 0 {
+		return response
+	}
+	generation, _ := strconv.Atoi(request.Params.Get(paramGeneration))
+	maxGenerations, _ := strconv.Atoi(storage.TaskMaxRetries)
+	if generation >= maxGenerations {
+		return response
+	}
+	params := request.Params.Without(paramFrom, paramTo)
+	params.SetString(paramIDs, strings.Join(result.failed, ","))
+	params.SetString(paramGeneration, strconv.Itoa(generation+1))
+	if err := response.SetFollowUp(params); err != nil {
+		log.WithFields(log.Fields{
+			"event":  "follow_up_failed",
+			"worker": workerID,
+			"taskID": request.ID,
+		}).Error(err)
+	}
+	return response
+}
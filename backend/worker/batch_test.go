@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+func TestBatchIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "comma-separated", ids: `"23, 24,,25"`, want: []string{"23", "24", "25"}},
+		{name: "numbers", ids: `[1, 2, 30000000000]`, want: []string{"1", "2", "30000000000"}},
+		{name: "strings", ids: `["007", "a,b", " "]`, want: []string{"007", "a,b"}},
+		{name: "duplicates", ids: `[7, "7", "007"]`, want: []string{"7", "007"}},
+		{name: "legacy number", ids: `23`, want: []string{"23"}},
+		{name: "empty", ids: `[]`, want: []string{}},
+		{name: "objects", ids: `[{"id": 1}]`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := protocol.Payload{paramIDs: json.RawMessage(test.ids)}
+			got, err := batchIDs(params)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ids = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
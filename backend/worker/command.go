@@ -0,0 +1,273 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"text/template"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+const (
+	// exitTempFail - EX_TEMPFAIL of sysexits.h, command asks to retry the task
+	exitTempFail = 75
+	// maxStdout - bytes of stdout parsed as task's result
+	maxStdout = 1 << 20
+	// commandWaitDelay - time given to killed command to close its output, a child out of its process group may hold it
+	commandWaitDelay = 5 * time.Second
+)
+
+// cappedBuffer keeps first limit bytes of output and drops the rest, so chatty commands don't block or exhaust memory
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if free := b.limit - b.Len(); free < len(p) {
+		b.truncated = true
+		if free > 0 {
+			b.Buffer.Write(p[:free])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// commandJob - run of an allowed executable, task can't change the executable, only its args, env and stdin
+type commandJob struct {
+	path    string
+	args    []*template.Template
+	env     map[string]*template.Template
+	inherit []string // Names of worker's environment variables passed to command
+	stdin   bool
+	dir     string
+	timeout time.Duration // Zero - worker's timeout
+	retry   map[int]bool
+	// waitDelay - wait for output of killed command, then it's abandoned
+	waitDelay time.Duration
+}
+
+// newCommand parses templates of command
+func newCommand(name string, command config.Command) (*commandJob, error) {
+	job := &commandJob{
+		path:    command.Path,
+		env:     map[string]*template.Template{},
+		inherit: command.InheritEnv,
+		stdin:   command.Stdin,
+		dir:     command.Dir,
+		timeout: time.Duration(command.Timeout) * time.Second,
+		retry:   map[int]bool{exitTempFail: true},
+
+		waitDelay: commandWaitDelay,
+	}
+	for i, arg := range command.Args {
+		tmpl, err := template.New(fmt.Sprintf("%s.args.%d", name, i)).Funcs(templateFuncs).Option("missingkey=zero").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		job.args = append(job.args, tmpl)
+	}
+	for key, value := range command.Env {
+		tmpl, err := template.New(name + ".env." + key).Funcs(templateFuncs).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		job.env[key] = tmpl
+	}
+	for _, code := range command.Retry {
+		job.retry[code] = true
+	}
+	return job, nil
+}
+
+// build renders command of task, its errors are permanent since retries render the same templates
+func (job *commandJob) build(request *protocol.Request) (*exec.Cmd, error) {
+	data := &templateData{
+		ID:      request.ID,
+		Attempt: strconv.Itoa(request.Attempt),
+		Params:  request.Params.Strings(),
+	}
+	args := make([]string, 0, len(job.args))
+	for _, tmpl := range job.args {
+		arg, err := render(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	cmd := exec.Command(job.path, args...)
+	cmd.Dir = job.dir
+	// Worker's environment holds credentials and DSNs, so command gets only PATH and inherited variables
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "TASK_ID=" + request.ID, "TASK_ATTEMPT=" + strconv.Itoa(request.Attempt)}
+	for _, key := range job.inherit {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	for key, tmpl := range job.env {
+		value, err := render(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	if job.stdin {
+		bin, err := json.Marshal(request.Params)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = bytes.NewReader(bin)
+	}
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// run starts command and kills its process group when ctx is done. Command's output may stay open
+// after the kill, e.g. by a child of another session, then it's abandoned after waitDelay.
+func (job *commandJob) run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		wait := time.NewTimer(job.waitDelay)
+		defer wait.Stop()
+		select {
+		case <-done:
+		case <-wait.C:
+			log.WithFields(log.Fields{
+				"event":   "command_abandoned",
+				"command": job.path,
+			}).Warn("killed command's output is still open")
+		}
+		return ctx.Err()
+	}
+}
+
+// parseOutput returns JSON object printed by command as result with its value types, other output is kept as is
+func parseOutput(stdout []byte) protocol.Payload {
+	stdout = bytes.TrimSpace(stdout)
+	result := protocol.Payload{}
+	if len(stdout) == 0 {
+		return result
+	}
+	if err := json.Unmarshal(stdout, &result); err != nil || result == nil {
+		result = protocol.Payload{}
+		result.SetString("output", string(stdout))
+	}
+	return result
+}
+
+// Handle runs command: exit code 0 is success, 75, configured codes and kills are retried, others are permanent errors
+func (job *commandJob) Handle(request *protocol.Request) *protocol.Response {
+	ctx := request.Context()
+	workerID := WorkerID(ctx)
+	if job.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.timeout)
+		defer cancel()
+	}
+	cmd, err := job.build(request)
+	if err != nil {
+		return protocol.NewError(request, "3", err.Error(), true)
+	}
+	stdout := &cappedBuffer{limit: maxStdout}
+	stderr := &cappedBuffer{limit: maxResponseBody}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = job.run(ctx, cmd)
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		log.WithFields(log.Fields{
+			"event":   "command_killed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"command": job.path,
+			"attempt": request.Attempt,
+		}).Warn(err)
+		return protocol.NewError(request, protocol.CodeTimeout, "command killed: "+err.Error(), false)
+	case errors.As(err, &exitErr):
+		code := exitErr.ExitCode()
+		message := string(bytes.TrimSpace(stderr.Bytes()))
+		if message == "" {
+			message = err.Error()
+		}
+		log.WithFields(log.Fields{
+			"event":   "command_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"command": job.path,
+			"exit":    code,
+			"attempt": request.Attempt,
+		}).Warn(message)
+		// Code -1 - killed by a signal, e.g. OOM killer
+		return protocol.NewError(request, strconv.Itoa(code), message, code >= 0 && !job.retry[code])
+	default:
+		// Executable is missing or not permitted, retries won't help
+		log.WithFields(log.Fields{
+			"event":   "command_start_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"command": job.path,
+			"attempt": request.Attempt,
+		}).Error(err)
+		return protocol.NewError(request, "4", err.Error(), true)
+	}
+	if stdout.truncated {
+		return protocol.NewError(request, "5", fmt.Sprintf("output is longer than %d bytes", maxStdout), true)
+	}
+	log.WithFields(log.Fields{
+		"event":   "command_processed",
+		"worker":  workerID,
+		"taskID":  request.ID,
+		"command": job.path,
+		"attempt": request.Attempt,
+	}).Info("successfully run command")
+	result := parseOutput(stdout.Bytes())
+	if !result.Has("result") {
+		result.SetString("result", "success")
+	}
+	response := protocol.NewResult(request, nil)
+	response.Result = result
+	return response
+}
+
+// commands returns handlers of allowed commands
+func commands(appCfg *config.AppConfig) (map[storage.Action]Handler, error) {
+	handlers := map[storage.Action]Handler{}
+	for name, command := range appCfg.Worker.Commands {
+		action, err := storage.ParseAction(name)
+		if err != nil {
+			return nil, fmt.Errorf("worker.commands: %w", err)
+		}
+		job, err := newCommand(name, command)
+		if err != nil {
+			return nil, fmt.Errorf("worker.commands.%s: %w", name, err)
+		}
+		handlers[action] = job.Handle
+	}
+	return handlers, nil
+}
@@ -0,0 +1,108 @@
+//go:build !windows
+// +build !windows
+
+package worker
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+func runScript(t *testing.T, script string, command config.Command) (*protocol.Response, time.Duration) {
+	t.Helper()
+	command.Path = "/bin/sh"
+	command.Args = []string{"-c", script}
+	job, err := newCommand("script", command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.timeout = 200 * time.Millisecond
+	job.waitDelay = 200 * time.Millisecond
+	start := time.Now()
+	response := job.Handle(&protocol.Request{ID: "42", Attempt: 3, Params: protocol.NewPayload(map[string]string{"objectID": "23"})})
+	return response, time.Since(start)
+}
+
+func TestCommandExitCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		retry     []int
+		code      string
+		permanent bool
+	}{
+		{name: "success", script: "exit 0"},
+		{name: "failure", script: "echo broken >&2; exit 1", code: "1", permanent: true},
+		{name: "temporary failure", script: "exit 75", code: "75"},
+		{name: "configured retry", script: "exit 3", retry: []int{3}, code: "3"},
+		{name: "killed by signal", script: "kill -9 $$", code: "-1"},
+		{name: "timeout", script: "sleep 10", code: protocol.CodeTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, _ := runScript(t, test.script, config.Command{Retry: test.retry})
+			if test.code == "" {
+				if len(response.Error) > 0 {
+					t.Fatalf("error = %s, want success", response.Error)
+				}
+				return
+			}
+			if response.Error.Get("code") != test.code || response.Permanent() != test.permanent {
+				t.Errorf("error = %s, want code %s and permanent %v", response.Error, test.code, test.permanent)
+			}
+		})
+	}
+}
+
+func TestCommandKillIsBounded(t *testing.T) {
+	// Child of another session survives the kill and keeps stdout open
+	response, took := runScript(t, "setsid sleep 5 & sleep 10", config.Command{})
+	if response.Error.Get("code") != protocol.CodeTimeout {
+		t.Errorf("error = %s, want timeout", response.Error)
+	}
+	if took > 2*time.Second {
+		t.Errorf("handler took %s after kill", took)
+	}
+}
+
+func TestCommandEnvironment(t *testing.T) {
+	os.Setenv("SCHEDULER_STORAGE_DSN", "postgres://secret")
+	os.Setenv("SCHEDULER_TEST_LOCALE", "C")
+	defer os.Unsetenv("SCHEDULER_STORAGE_DSN")
+	defer os.Unsetenv("SCHEDULER_TEST_LOCALE")
+	response, _ := runScript(t, `printf '{"dsn":"%s","locale":"%s","task":"%s","attempt":"%s","object":"%s"}' "$SCHEDULER_STORAGE_DSN" "$SCHEDULER_TEST_LOCALE" "$TASK_ID" "$TASK_ATTEMPT" "$OBJECT"`, config.Command{
+		Env:        map[string]string{"OBJECT": "{{.Params.objectID}}"},
+		InheritEnv: []string{"SCHEDULER_TEST_LOCALE"},
+	})
+	want := map[string]string{"dsn": "", "locale": "C", "task": "42", "attempt": "3", "object": "23", "result": "success"}
+	for key, value := range want {
+		if got := response.Result.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "typed JSON", script: `echo '{"exported": 2, "ids": [1, 2], "result": "partial"}'`, want: `{"exported":2,"ids":[1,2],"result":"partial"}`},
+		{name: "text", script: "echo done", want: `{"output":"done","result":"success"}`},
+		{name: "JSON array", script: "echo '[1]'", want: `{"output":"[1]","result":"success"}`},
+		{name: "empty", script: "true", want: `{"result":"success"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, _ := runScript(t, test.script, config.Command{})
+			if got := response.Result.String(); got != test.want {
+				t.Errorf("result = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
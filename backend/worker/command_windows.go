@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package worker
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, there are no process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills command only
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,227 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// DefaultExport - built-in `export` action, copies t_object to t_exported_object by objectID param
+func DefaultExport() config.Export {
+	var export config.Export
+	export.Source.Table = "t_object"
+	export.Source.Key = map[string]string{"id": "objectID"}
+	export.Target.Table = "t_exported_object"
+	export.Columns = map[string]string{"id": "id", "data": "data"}
+	export.OnConflict = "skip"
+	return export
+}
+
+// exportJob - SQL-to-SQL export of one row, duplicates are skipped or upserted, so retries are idempotent
+type exportJob struct {
+	source      *pgxpool.Pool
+	target      *pgxpool.Pool
+	selectQuery string
+	insertQuery string
+	params      []string // Task's params of key columns in select's order
+
+	// Batches, see batch.go
+	batchSelect string // `select <columns>, <key>::text from <source>`
+	idsSelect   string // `select <columns>, <input id> from <source> join <input ids>`, $1 - ids, $2 - key column
+	keyColumn   string // Unquoted key column
+	insertInto  string // `insert into <target> (<columns>)`
+	onConflict  string
+	key         string // Key column if there is only one
+	keyIndex    int    // Position of key column in selected row
+	width       int    // Columns per row
+	batchSize   int
+}
+
+// identifier quotes possibly schema-qualified name
+func identifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// newExport builds queries of export job, pools are shared with other handlers
+func newExport(export config.Export, resources *Resources) (*exportJob, error) {
+	source, err := resources.Pool(export.Source.DSN)
+	if err != nil {
+		return nil, err
+	}
+	target, err := resources.Pool(export.Target.DSN)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(export.Columns))
+	for column := range export.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	keys := make([]string, 0, len(export.Source.Key))
+	for column := range export.Source.Key {
+		keys = append(keys, column)
+	}
+	sort.Strings(keys)
+
+	job := &exportJob{source: source, target: target}
+	selected := make([]string, 0, len(columns))
+	inserted := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	updates := []string{}
+	isKey := map[string]bool{}
+	for _, column := range keys {
+		isKey[column] = true
+	}
+	for i, column := range columns {
+		targetColumn := export.Columns[column]
+		if targetColumn == "" {
+			targetColumn = column
+		}
+		selected = append(selected, identifier(column))
+		inserted = append(inserted, identifier(targetColumn))
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		if !isKey[column] {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", identifier(targetColumn), identifier(targetColumn)))
+		}
+	}
+	conditions := make([]string, 0, len(keys))
+	conflict := make([]string, 0, len(keys))
+	for i, column := range keys {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", identifier(column), i+1))
+		targetColumn := export.Columns[column]
+		if targetColumn == "" {
+			targetColumn = column
+		}
+		conflict = append(conflict, identifier(targetColumn))
+		job.params = append(job.params, export.Source.Key[column])
+	}
+	job.insertInto = fmt.Sprintf("insert into %s (%s)", identifier(export.Target.Table), strings.Join(inserted, ", "))
+	if export.OnConflict == "upsert" && len(updates) > 0 {
+		job.onConflict = fmt.Sprintf(" on conflict (%s) do update set %s", strings.Join(conflict, ", "), strings.Join(updates, ", "))
+	} else {
+		job.onConflict = " on conflict do nothing"
+	}
+	job.selectQuery = fmt.Sprintf(
+		"select %s from %s where %s",
+		strings.Join(selected, ", "), identifier(export.Source.Table), strings.Join(conditions, " and "),
+	)
+	job.insertQuery = job.insertInto + " values (" + strings.Join(placeholders, ", ") + ")" + job.onConflict
+
+	job.width = len(columns)
+	job.batchSize = export.BatchSize
+	if job.batchSize <= 0 {
+		job.batchSize = defaultBatchSize
+	}
+	// Postgres accepts at most 65535 params per statement
+	if job.batchSize*job.width > maxParams {
+		job.batchSize = maxParams / job.width
+	}
+	if len(keys) == 1 {
+		job.key = identifier(keys[0])
+		job.keyIndex = sort.SearchStrings(columns, keys[0])
+		job.keyColumn = keys[0]
+		job.batchSelect = fmt.Sprintf(
+			"select %s, %s::text from %s",
+			strings.Join(selected, ", "), job.key, identifier(export.Source.Table),
+		)
+		// Input ids are cast to key's type by the source's row type, so "007" matches 7 and is reported as "007"
+		job.idsSelect = fmt.Sprintf(
+			"select %s, ids.id from %s join unnest($1::text[]) as ids(id) on %s = (json_populate_record(null::%s, json_build_object($2::text, ids.id))).%s",
+			strings.Join(selected, ", "), identifier(export.Source.Table), job.key, identifier(export.Source.Table), job.key,
+		)
+	}
+	return job, nil
+}
+
+// Handle copies row selected by task's key params or a batch of rows
+func (job *exportJob) Handle(request *protocol.Request) *protocol.Response {
+	if isBatch(request) {
+		return job.handleBatch(request)
+	}
+	ctx := request.Context()
+	workerID := WorkerID(ctx)
+	args := make([]interface{}, 0, len(job.params))
+	for _, param := range job.params {
+		if !request.Params.Has(param) {
+			return protocol.NewError(request, "3", fmt.Sprintf("no %s supported", param), true)
+		}
+		args = append(args, request.Params.Get(param))
+	}
+	var values []interface{}
+	rows, err := job.source.Query(ctx, job.selectQuery, args...)
+	if err == nil {
+		if rows.Next() {
+			values, err = rows.Values()
+		} else if err = rows.Err(); err == nil {
+			err = pgx.ErrNoRows
+		}
+		rows.Close()
+	}
+	err = fault.Inject("worker.export.select", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "select_object_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"key":     args,
+			"attempt": request.Attempt,
+		}).Error(err)
+		return protocol.NewError(request, "1", err.Error(), false)
+	}
+	tag, err := job.target.Exec(ctx, job.insertQuery, values...)
+	err = fault.Inject("worker.export.insert", err)
+	if err != nil && !storage.IsUniqueViolation(err) {
+		log.WithFields(log.Fields{
+			"event":   "insert_object_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"key":     args,
+			"attempt": request.Attempt,
+		}).Error(err)
+		return protocol.NewError(request, "2", err.Error(), false)
+	}
+	log.WithFields(log.Fields{
+		"event":   "object_processed",
+		"worker":  workerID,
+		"taskID":  request.ID,
+		"key":     args,
+		"attempt": request.Attempt,
+	}).Info("successfully export object")
+	return protocol.NewResult(request, map[string]string{
+		"result": "success",
+		"rows":   strconv.FormatInt(tag.RowsAffected(), 10),
+	})
+}
+
+// exports returns handlers of configured export jobs, `export` action falls back to DefaultExport
+func exports(appCfg *config.AppConfig, resources *Resources) (map[storage.Action]Handler, error) {
+	jobs := map[storage.Action]config.Export{
+		storage.EXPORT: DefaultExport(),
+	}
+	for name, export := range appCfg.Worker.Exports {
+		action, err := storage.ParseAction(name)
+		if err != nil {
+			return nil, fmt.Errorf("worker.exports: %w", err)
+		}
+		jobs[action] = export
+	}
+	handlers := map[storage.Action]Handler{}
+	for action, export := range jobs {
+		job, err := newExport(export, resources)
+		if err != nil {
+			return nil, fmt.Errorf("worker.exports.%s: %w", strings.ToLower(string(action)), err)
+		}
+		handlers[action] = job.Handle
+	}
+	return handlers, nil
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "scheduler",
+		Subsystem: "worker",
+		Name:      "handler_duration_seconds",
+		Help:      "Handler processing time per action.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"action"})
+	handlerResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "worker",
+		Name:      "handler_results_total",
+		Help:      "Handler results per action, status and error code.",
+	}, []string{"action", "status", "code"})
+	timeoutResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "worker",
+		Name:      "timeout_responses_total",
+		Help:      "Attempts answered with timeout error because handler exceeded action's timeout or task's deadline.",
+	}, []string{"action"})
+	abandonedHandlers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scheduler",
+		Subsystem: "worker",
+		Name:      "abandoned_handlers",
+		Help:      "Handlers still running after timeout and grace period, they ignore context and exceed worker.workers.",
+	}, []string{"action"})
+	handlerPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scheduler",
+		Subsystem: "worker",
+		Name:      "handler_panics_total",
+		Help:      "Handlers recovered from panic.",
+	}, []string{"action"})
+)
@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/freundallein/scheduler/backend/chassis/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps handler of action with cross-cutting behavior, e.g. auth checks or payload validation
+type Middleware func(action storage.Action, next Handler) Handler
+
+// Classifier reports whether response's error is permanent, i.e. task must not be retried
+type Classifier func(request *protocol.Request, response *protocol.Response) bool
+
+var middlewares []Middleware
+
+// Use adds middlewares to every handler in given order. They are applied outside Timeout and Recover,
+// so they see timeout and panic responses, e.g. to classify them. It must be called before roles are started.
+func Use(mws ...Middleware) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	middlewares = append(middlewares, mws...)
+}
+
+// Chain wraps handler with middlewares, the first one is outermost
+func Chain(action storage.Action, handler Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](action, handler)
+	}
+	return handler
+}
+
+// chain returns built-in middlewares with registered ones between Attempt and Timeout
+func chain(cfg *Config) []Middleware {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	mws := []Middleware{
+		Tracing,
+		Metrics,
+		Attempt,
+	}
+	mws = append(mws, middlewares...)
+	return append(mws, Timeout(cfg.Timeouts, cfg.Timeout), Recover)
+}
+
+// Tracing starts span of handled attempt under submit span and passes it to handler with request's context
+func Tracing(action storage.Action, next Handler) Handler {
+	return func(request *protocol.Request) *protocol.Response {
+		ctx, span := tracing.Start(
+			tracing.Extract(request.Context(), request.Trace),
+			"handle "+string(action),
+			trace.SpanKindConsumer,
+			attribute.String("taskID", request.ID),
+			attribute.String("action", string(action)),
+			attribute.Int("attempt", request.Attempt),
+		)
+		defer span.End()
+		response := next(request.WithContext(ctx))
+		if len(response.Error) > 0 {
+			span.SetAttributes(attribute.String("error.code", response.Error.Get("code")))
+			span.SetStatus(codes.Error, response.Error.Get("message"))
+		}
+		response.Trace = tracing.Inject(ctx)
+		return response
+	}
+}
+
+// Metrics observes handler duration and results
+func Metrics(action storage.Action, next Handler) Handler {
+	return func(request *protocol.Request) *protocol.Response {
+		start := time.Now()
+		response := next(request)
+		handlerDuration.WithLabelValues(string(action)).Observe(time.Since(start).Seconds())
+		if len(response.Error) == 0 {
+			handlerResults.WithLabelValues(string(action), "success", "").Inc()
+		} else {
+			handlerResults.WithLabelValues(string(action), "error", response.Error.Get("code")).Inc()
+		}
+		return response
+	}
+}
+
+// Attempt sets request's IDs and attempt to response, so resulter matches it with task's current attempt
+func Attempt(action storage.Action, next Handler) Handler {
+	return func(request *protocol.Request) *protocol.Response {
+		response := next(request)
+		response.ID = request.ID
+		response.TaskID = request.TaskID
+		response.Attempt = request.Attempt
+		if len(response.Error) == 0 && response.Result == nil {
+			response.Result = protocol.Payload{}
+		}
+		return response
+	}
+}
+
+// timeoutGrace - time given to handler to return after its context is done
+const timeoutGrace = 5 * time.Second
+
+// handlerDeadline returns the earlier of action's timeout and task's deadline with its error message, zero - no limit
+func handlerDeadline(timeout time.Duration, deadline time.Time) (time.Time, string) {
+	if timeout > 0 && (deadline.IsZero() || time.Now().Add(timeout).Before(deadline)) {
+		return time.Now().Add(timeout), fmt.Sprintf("handler timeout %s", timeout)
+	}
+	return deadline, "task deadline exceeded"
+}
+
+// Timeout limits handler's duration by action's timeout and request's deadline, whichever is earlier,
+// handler gets request's context with deadline and must return when it's done. Worker waits for it
+// timeoutGrace more, then handler is abandoned (scheduler_worker_abandoned_handlers) and worker takes
+// the next message. Response of a late handler is dropped, zero timeout and no deadline mean no limit.
+func Timeout(timeouts map[storage.Action]time.Duration, fallback time.Duration) Middleware {
+	return func(action storage.Action, next Handler) Handler {
+		timeout, ok := timeouts[action]
+		if !ok {
+			timeout = fallback
+		}
+		return func(request *protocol.Request) *protocol.Response {
+			deadline, message := handlerDeadline(timeout, request.Deadline)
+			if deadline.IsZero() {
+				return next(request)
+			}
+			ctx, cancel := context.WithDeadline(request.Context(), deadline)
+			defer cancel()
+			done := make(chan *protocol.Response, 1)
+			go func() {
+				done <- next(request.WithContext(ctx))
+			}()
+			select {
+			case response := <-done:
+				return response
+			case <-ctx.Done():
+			}
+			timeoutResponses.WithLabelValues(string(action)).Inc()
+			grace := time.NewTimer(timeoutGrace)
+			defer grace.Stop()
+			select {
+			case <-done:
+				// Handler honored its context
+			case <-grace.C:
+				abandoned := abandonedHandlers.WithLabelValues(string(action))
+				abandoned.Inc()
+				log.WithFields(log.Fields{
+					"event":  "handler_abandoned",
+					"taskID": request.ID,
+					"action": action,
+				}).Warn("handler ignores context after timeout")
+				go func() {
+					<-done
+					abandoned.Dec()
+				}()
+			}
+			return protocol.NewError(request, protocol.CodeTimeout, message, false)
+		}
+	}
+}
+
+// Recover converts handler's panic or missing response to error response with stack digest
+func Recover(action storage.Action, next Handler) Handler {
+	return func(request *protocol.Request) *protocol.Response {
+		var response *protocol.Response
+		err := recovery.Do("worker", func() {
+			response = next(request)
+		})
+		var p *recovery.Panic
+		if errors.As(err, &p) {
+			handlerPanics.WithLabelValues(string(action)).Inc()
+			log.WithFields(log.Fields{
+				"event":  "handler_panic",
+				"taskID": request.ID,
+				"action": action,
+				"digest": p.Digest,
+			}).Error(err)
+			response = protocol.NewError(request, protocol.CodeInternal, p.Error(), false)
+			response.Error.SetString("stack", p.Digest)
+		}
+		if response == nil {
+			response = protocol.NewError(request, protocol.CodeInternal, "handler returned no response", false)
+		}
+		return response
+	}
+}
+
+// Classify returns middleware which marks errors as permanent or retryable by classifier
+func Classify(classifier Classifier) Middleware {
+	return func(action storage.Action, next Handler) Handler {
+		return func(request *protocol.Request) *protocol.Response {
+			response := next(request)
+			response.SetPermanent(classifier(request, response))
+			return response
+		}
+	}
+}
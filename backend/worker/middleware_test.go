@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+func TestClassifySeesBuiltInErrors(t *testing.T) {
+	registryMu.Lock()
+	saved := middlewares
+	middlewares = nil
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		middlewares = saved
+		registryMu.Unlock()
+	}()
+	var seen []string
+	Use(Classify(func(request *protocol.Request, response *protocol.Response) bool {
+		code := response.Error.Get("code")
+		seen = append(seen, code)
+		return code == protocol.CodeInternal
+	}))
+	tests := []struct {
+		name      string
+		handler   Handler
+		code      string
+		permanent bool
+	}{
+		{
+			name: "timeout",
+			handler: func(request *protocol.Request) *protocol.Response {
+				<-request.Context().Done()
+				return protocol.NewResult(request, nil)
+			},
+			code: protocol.CodeTimeout,
+		},
+		{
+			name: "panic",
+			handler: func(request *protocol.Request) *protocol.Response {
+				panic("broken handler")
+			},
+			code:      protocol.CodeInternal,
+			permanent: true,
+		},
+		{
+			name: "no response",
+			handler: func(request *protocol.Request) *protocol.Response {
+				return nil
+			},
+			code:      protocol.CodeInternal,
+			permanent: true,
+		},
+	}
+	cfg := &Config{Timeout: 10 * time.Millisecond}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seen = nil
+			handler := Chain(storage.Action("test"), test.handler, chain(cfg)...)
+			request := (&protocol.Request{ID: "1", Attempt: 1}).WithContext(context.Background())
+			response := handler(request)
+			if len(seen) != 1 || seen[0] != test.code {
+				t.Fatalf("classifier saw %v, want %s", seen, test.code)
+			}
+			if response.Error.Get("code") != test.code || response.Permanent() != test.permanent {
+				t.Errorf("error = %s, want code %s and permanent %v", response.Error, test.code, test.permanent)
+			}
+		})
+	}
+}
+
+func TestHandlerDeadline(t *testing.T) {
+	soon := time.Now().Add(time.Second)
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		deadline time.Time
+		want     time.Duration // Expected time left, zero - no limit
+		message  string
+	}{
+		{name: "no limit"},
+		{name: "timeout", timeout: time.Minute, want: time.Minute, message: "handler timeout 1m0s"},
+		{name: "earlier timeout", timeout: time.Minute, deadline: later, want: time.Minute, message: "handler timeout 1m0s"},
+		{name: "earlier deadline", timeout: time.Minute, deadline: soon, want: time.Second, message: "task deadline exceeded"},
+		{name: "deadline only", deadline: later, want: time.Hour, message: "task deadline exceeded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deadline, message := handlerDeadline(test.timeout, test.deadline)
+			if test.want == 0 {
+				if !deadline.IsZero() {
+					t.Errorf("deadline = %s, want none", deadline)
+				}
+				return
+			}
+			if left := time.Until(deadline); left > test.want || left < test.want-100*time.Millisecond {
+				t.Errorf("time left = %s, want %s", left, test.want)
+			}
+			if message != test.message {
+				t.Errorf("message = %q, want %q", message, test.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+// unknownAction - metrics and tracing label of tasks without handler
+const unknownAction storage.Action = "unknown"
+
+// Handler - executes a task and returns its result or error
+type Handler func(*protocol.Request) *protocol.Response
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[storage.Action]Handler{}
+)
+
+// Register adds handler of a custom action, e.g. worker.Register("resize_image", handler).
+// Action is added to the shared catalog, so submitter accepts `submit:resize_image`.
+// It must be called before roles are started, registered handler replaces built-in one.
+func Register(name string, handler Handler) storage.Action {
+	action := storage.RegisterAction(name)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[action] = handler
+	return action
+}
+
+// rejectUnknown returns permanent error, resulter moves task to CRITICAL_ERROR
+func rejectUnknown(err error) Handler {
+	return func(request *protocol.Request) *protocol.Response {
+		return protocol.NewError(request, protocol.CodeMethodNotFound, err.Error(), true)
+	}
+}
+
+// handlers returns built-in and registered handlers wrapped with middlewares
+func handlers(cfg *Config) map[storage.Action]Handler {
+	result := map[storage.Action]Handler{
+		storage.DUMMY: HandleDummy,
+	}
+	for action, handler := range cfg.Actions {
+		result[action] = handler
+	}
+	registryMu.RLock()
+	for action, handler := range registry {
+		result[action] = handler
+	}
+	registryMu.RUnlock()
+	mws := chain(cfg)
+	for action, handler := range result {
+		result[action] = Chain(action, handler, mws...)
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Resources - clients shared by all handlers of the process, handlers get them with FromContext
+type Resources struct {
+	// DB - pool of worker.resources.db, nil if no DSN is configured
+	DB *pgxpool.Pool
+	// HTTP - client limited by worker.resources.http
+	HTTP *http.Client
+
+	mu      sync.RWMutex
+	custom  map[string]interface{}
+	pools   map[string]*pgxpool.Pool
+	closers []func()
+	// maxConns - limit of pools opened with Pool
+	maxConns int
+}
+
+// Provider opens custom resource, returned close function is called on shutdown
+type Provider func(appCfg *config.AppConfig) (resource interface{}, close func(), err error)
+
+var providers = map[string]Provider{}
+
+// Provide registers custom resource, e.g. cache client, it's opened once when worker role starts.
+// It must be called before roles are started.
+func Provide(name string, provider Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providers[name] = provider
+}
+
+type resourcesKey struct{}
+
+type workerKey struct{}
+
+// WorkerID returns ID of worker goroutine which runs handler, it's used in logs
+func WorkerID(ctx context.Context) int {
+	workerID, _ := ctx.Value(workerKey{}).(int)
+	return workerID
+}
+
+// FromContext returns resources passed to handler with request's context
+func FromContext(ctx context.Context) *Resources {
+	resources, _ := ctx.Value(resourcesKey{}).(*Resources)
+	if resources == nil {
+		return &Resources{HTTP: http.DefaultClient}
+	}
+	return resources
+}
+
+// WithResources returns ctx which carries resources, worker passes it to every handler
+func WithResources(ctx context.Context, resources *Resources) context.Context {
+	return context.WithValue(ctx, resourcesKey{}, resources)
+}
+
+// OpenResources opens DB pool, HTTP client and registered custom resources
+func OpenResources(appCfg *config.AppConfig) (*Resources, error) {
+	settings := appCfg.Worker.Resources
+	resources := &Resources{
+		HTTP: &http.Client{
+			Timeout: time.Duration(settings.HTTP.Timeout) * time.Second,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        settings.HTTP.MaxIdleConns,
+				MaxIdleConnsPerHost: settings.HTTP.MaxIdleConns,
+				MaxConnsPerHost:     settings.HTTP.MaxConnsPerHost,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		custom:   map[string]interface{}{},
+		pools:    map[string]*pgxpool.Pool{},
+		maxConns: settings.DB.MaxConns,
+	}
+	dsn := settings.DB.DSN
+	if dsn == "" {
+		dsn = appCfg.Storage.DSN
+	}
+	if dsn != "" {
+		pool, err := storage.NewPool(storage.Config{DSN: dsn, MaxConns: settings.DB.MaxConns})
+		if err != nil {
+			return nil, fmt.Errorf("worker.resources.db: %w", err)
+		}
+		resources.DB = pool
+		resources.closers = append(resources.closers, pool.Close)
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for name, provider := range providers {
+		resource, close, err := provider(appCfg)
+		if err != nil {
+			resources.Close()
+			return nil, fmt.Errorf("resource %s: %w", name, err)
+		}
+		resources.custom[name] = resource
+		if close != nil {
+			resources.closers = append(resources.closers, close)
+		}
+	}
+	return resources, nil
+}
+
+// Get returns custom resource registered with Provide
+func (r *Resources) Get(name string) (interface{}, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resource, ok := r.custom[name]
+	return resource, ok
+}
+
+// Pool returns pool of dsn, it's opened once and shared by handlers. Empty dsn means DB.
+func (r *Resources) Pool(dsn string) (*pgxpool.Pool, error) {
+	if dsn == "" {
+		if r.DB == nil {
+			return nil, errors.New("no database configured in worker.resources.db")
+		}
+		return r.DB, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pool, ok := r.pools[dsn]; ok {
+		return pool, nil
+	}
+	pool, err := storage.NewPool(storage.Config{DSN: dsn, MaxConns: r.maxConns})
+	if err != nil {
+		return nil, err
+	}
+	r.pools[dsn] = pool
+	r.closers = append(r.closers, pool.Close)
+	return pool, nil
+}
+
+// Close releases all resources, it's called after handlers are finished
+func (r *Resources) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		r.closers[i]()
+	}
+	r.closers = nil
+	if transport, ok := r.HTTP.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
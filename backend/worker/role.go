@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/freundallein/scheduler/backend/chassis/app"
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/health"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+// Role - executes tasks and sends results
+func Role() app.Role {
+	return app.Role{
+		Name: "worker",
+		Register: func(appCfg *config.AppConfig) error {
+			for name := range appCfg.Worker.Exports {
+				storage.RegisterAction(name)
+			}
+			for name := range appCfg.Worker.Webhooks {
+				storage.RegisterAction(name)
+			}
+			for name := range appCfg.Worker.Commands {
+				storage.RegisterAction(name)
+			}
+			return nil
+		},
+		Start: func(rt *app.Runtime) (func(*config.AppConfig), error) {
+			appCfg := rt.Config()
+			timeouts := map[storage.Action]time.Duration{}
+			for name, timeout := range appCfg.Worker.Timeouts {
+				action, err := storage.ParseAction(name)
+				if err != nil {
+					return nil, fmt.Errorf("worker.timeouts: %w", err)
+				}
+				timeouts[action] = time.Duration(timeout) * time.Second
+			}
+			resources, err := OpenResources(appCfg)
+			if err != nil {
+				return nil, err
+			}
+			rt.OnShutdown(resources.Close)
+			if resources.DB != nil {
+				health.Register("worker.db", func(ctx context.Context) error {
+					_, err := resources.DB.Exec(ctx, "select 1")
+					return err
+				})
+			}
+			actions, err := exports(appCfg, resources)
+			if err != nil {
+				return nil, err
+			}
+			webhookHandlers, err := webhooks(appCfg)
+			if err != nil {
+				return nil, err
+			}
+			for action, handler := range webhookHandlers {
+				actions[action] = handler
+			}
+			commandHandlers, err := commands(appCfg)
+			if err != nil {
+				return nil, err
+			}
+			for action, handler := range commandHandlers {
+				actions[action] = handler
+			}
+			cfg := &Config{
+				QueueSrc:   rt.Queue(appCfg.Worker.Queuesrc),
+				QueueDst:   rt.Queue(appCfg.Worker.Queuedst),
+				DeadLetter: rt.DeadLetter(),
+				Resources:  resources,
+				Actions:    actions,
+				Workers:    appCfg.Worker.Workers,
+				Timeout:    time.Duration(appCfg.Worker.Timeout) * time.Second,
+				Timeouts:   timeouts,
+				Version:    appCfg.Protocol.Version,
+			}
+			workers := Run(rt.Context(), cfg, rt.Group())
+			return func(current *config.AppConfig) {
+				workers.Resize(current.Worker.Workers)
+			}, nil
+		},
+	}
+}
@@ -2,13 +2,19 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/health"
 	log "github.com/freundallein/scheduler/backend/chassis/logging"
-	"github.com/freundallein/scheduler/backend/chassis/monkey"
 
+	"github.com/freundallein/scheduler/backend/chassis/pool"
 	"github.com/freundallein/scheduler/backend/chassis/protocol"
 	"github.com/freundallein/scheduler/backend/chassis/queue"
+	"github.com/freundallein/scheduler/backend/chassis/recovery"
 	"github.com/freundallein/scheduler/backend/chassis/storage"
 )
 
@@ -16,17 +22,106 @@ import (
 type Config struct {
 	QueueSrc   queue.Client
 	QueueDst   queue.Client
-	StorageDSN string
-	Workers    int
+	DeadLetter queue.Client
+	Resources  *Resources
+	// Actions - handlers of config-defined actions: worker.exports, worker.webhooks and worker.commands
+	Actions map[storage.Action]Handler
+	Workers int
+	// Timeout of handlers, zero means no limit
+	Timeout  time.Duration
+	Timeouts map[storage.Action]time.Duration
+	// Version of sent responses, protocol.V0 or protocol.V1
+	Version int
 }
 
-func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
+// handle executes task and sends its result
+func handle(cfg *Config, handlers map[storage.Action]Handler, workerID int, msg *queue.RecvMessage) {
 	cliSrc := cfg.QueueSrc
 	cliDst := cfg.QueueDst
-	var handlers = map[storage.Action]func(*protocol.Request) *protocol.Response{
-		storage.EXPORT: HandleExport(cfg.StorageDSN, workerID),
-		storage.DUMMY:  HandleDummy,
+
+	request := &protocol.Request{}
+	err := request.FromJSON(msg.Body)
+	err = fault.Inject("worker.decode", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "receive_broken_message",
+			"worker": workerID,
+		}).Error(err)
+		return
+	}
+	action, err := storage.ParseAction(request.Method)
+	handler, ok := handlers[action]
+	if !ok {
+		if err == nil {
+			err = fmt.Errorf("%w: no handler of %s", storage.ErrUnknownAction, action)
+		}
+		log.WithFields(log.Fields{
+			"event":  "unknown_action",
+			"worker": workerID,
+			"taskID": request.ID,
+		}).Error(err)
+		// Permanent error is sent as a result, resulter stops the task instead of retrying it
+		action = unknownAction
+		handler = Chain(action, rejectUnknown(err), chain(cfg)...)
+	}
+	log.WithFields(log.Fields{
+		"event":   "receive_message",
+		"worker":  workerID,
+		"action":  action,
+		"taskID":  request.ID,
+		"attempt": request.Attempt,
+		"params":  request.Params,
+	}).Info("receive task")
+	// Worker is alive while handler runs within its limit, a handler without limit may run forever
+	timeout, ok := cfg.Timeouts[action]
+	if !ok {
+		timeout = cfg.Timeout
 	}
+	var within time.Duration
+	if deadline, _ := handlerDeadline(timeout, request.Deadline); !deadline.IsZero() {
+		within = time.Until(deadline) + timeoutGrace + health.StaleAfter
+	}
+	health.BeatWithin("worker", workerID, within)
+	// Handler finishes current task on shutdown, so it doesn't get worker's ctx
+	handlerCtx := context.WithValue(WithResources(context.Background(), cfg.Resources), workerKey{}, workerID)
+	response := handler(request.WithContext(handlerCtx))
+	response.Version = cfg.Version
+
+	jsonMsg, err := response.JSON()
+	err = fault.Inject("worker.encode", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "response_serialize_failed",
+			"worker": workerID,
+			"taskID": request.ID,
+		}).Error(err)
+		return
+	}
+	err = fault.Send("worker.send", func() error {
+		return cliDst.SendMessage(jsonMsg)
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "result_send_failed",
+			"worker": workerID,
+			"taskID": request.ID,
+		}).Error(err)
+		return
+	}
+	err = cliSrc.Acknowledge(msg)
+	err = fault.Inject("worker.ack", err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":  "ack_message_failed",
+			"worker": workerID,
+			"taskID": request.ID,
+		}).Error(err)
+	}
+}
+
+func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGroup) {
+	cliSrc := cfg.QueueSrc
+	handlers := handlers(cfg)
 	for {
 		select {
 		case <-ctx.Done():
@@ -34,94 +129,38 @@ func worker(ctx context.Context, cfg *Config, workerID int, group *sync.WaitGrou
 				"event":  "ctx_canceled",
 				"worker": workerID,
 			}).Info("exit goroutine")
+			health.Forget("worker", workerID)
 			group.Done()
 			return
 		default:
+			health.Beat("worker", workerID)
 			msg, err := cliSrc.ReceiveMessage()
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "receive_failed",
-					"worker": workerID,
-				}).Error(err)
+			err = fault.Inject("worker.receive", err)
+			if errors.Is(err, queue.ErrNoMessage) {
 				continue
 			}
-			request := &protocol.Request{}
-			err = request.FromJSON(msg.Body)
-			err = monkey.RandomizeError(err)
 			if err != nil {
 				log.WithFields(log.Fields{
-					"event":  "receive_broken_message",
-					"worker": workerID,
-				}).Error(err)
-				continue
-			}
-			var action storage.Action
-			switch request.Method {
-			case string(storage.EXPORT):
-				action = storage.EXPORT
-			default:
-				action = storage.DUMMY
-			}
-			log.WithFields(log.Fields{
-				"event":    "receive_message",
-				"worker":   workerID,
-				"action":   action,
-				"taskID":   request.ID,
-				"objectID": request.Params["objectID"],
-			}).Info(request)
-			handler, ok := handlers[action]
-			if !ok {
-				log.WithFields(log.Fields{
-					"event":  "handler_not_found",
-					"worker": workerID,
-					"taskID": request.ID,
-				}).Error(action)
-				continue
-			}
-			response := handler(request)
-
-			jsonMsg, err := response.JSON()
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "response_serialize_failed",
-					"worker": workerID,
-					"taskID": request.ID,
-				}).Error(err)
-				continue
-			}
-			err = cliDst.SendMessage(jsonMsg)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "result_send_failed",
-					"worker": workerID,
-					"taskID": request.ID,
-				}).Error(err)
-				continue
-			}
-			err = cliSrc.Acknowledge(msg)
-			err = monkey.RandomizeError(err)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"event":  "ack_message_failed",
+					"event":  "receive_failed",
 					"worker": workerID,
-					"taskID": request.ID,
 				}).Error(err)
 				continue
 			}
+			recovery.Message("worker", cliSrc, cfg.DeadLetter, msg, func() {
+				handle(cfg, handlers, workerID, msg)
+			})
 		}
 	}
 }
 
-// Run ...
-func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) {
+// Run starts workers, returned pool can be resized at runtime
+func Run(ctx context.Context, cfg *Config, group *sync.WaitGroup) *pool.Pool {
 	log.WithFields(log.Fields{
 		"event": "start_service",
 	}).Info("starting ", cfg.Workers, " workers")
-	for wrk := 1; wrk <= cfg.Workers; wrk++ {
-		group.Add(1)
-		go worker(ctx, cfg, wrk, group)
-	}
+	workers := pool.New(ctx, group, func(ctx context.Context, workerID int) {
+		worker(ctx, cfg, workerID, group)
+	})
+	workers.Resize(cfg.Workers)
+	return workers
 }
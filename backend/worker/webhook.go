@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/freundallein/scheduler/backend/chassis/logging"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/fault"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+	"github.com/freundallein/scheduler/backend/chassis/storage"
+)
+
+// maxResponseBody - bytes of response body kept in task's result or error
+const maxResponseBody = 4096
+
+// templateFuncs - helpers of webhook templates
+var templateFuncs = template.FuncMap{
+	"query": url.QueryEscape,
+	"path":  url.PathEscape,
+	"json": func(value interface{}) (string, error) {
+		bin, err := json.Marshal(value)
+		return string(bin), err
+	},
+	"env": os.Getenv,
+}
+
+// templateData - data of webhook and command templates
+type templateData struct {
+	ID      string
+	Attempt string
+	Params  map[string]string
+}
+
+// webhookJob - HTTP call of a configured endpoint
+type webhookJob struct {
+	method  string
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template // nil - params as JSON object
+	timeout time.Duration      // Zero - client's timeout
+	retry   map[int]bool
+}
+
+// newWebhook parses templates of webhook
+func newWebhook(name string, webhook config.Webhook) (*webhookJob, error) {
+	job := &webhookJob{
+		method:  strings.ToUpper(webhook.Method),
+		headers: map[string]*template.Template{},
+		timeout: time.Duration(webhook.Timeout) * time.Second,
+		retry:   map[int]bool{},
+	}
+	if job.method == "" {
+		job.method = http.MethodPost
+	}
+	var err error
+	job.url, err = template.New(name + ".url").Funcs(templateFuncs).Option("missingkey=zero").Parse(webhook.URL)
+	if err != nil {
+		return nil, err
+	}
+	for header, value := range webhook.Headers {
+		job.headers[header], err = template.New(name + "." + header).Funcs(templateFuncs).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if webhook.Body != "" {
+		job.body, err = template.New(name + ".body").Funcs(templateFuncs).Option("missingkey=zero").Parse(webhook.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, status := range webhook.Retry {
+		job.retry[status] = true
+	}
+	return job, nil
+}
+
+// render executes template with task's data
+func render(tmpl *template.Template, data *templateData) (string, error) {
+	var buff strings.Builder
+	err := tmpl.Execute(&buff, data)
+	return buff.String(), err
+}
+
+// retryable reports whether response status is worth another attempt
+func (job *webhookJob) retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500 || job.retry[status]
+}
+
+// build renders HTTP request of task, its errors are permanent since retries render the same templates
+func (job *webhookJob) build(ctx context.Context, request *protocol.Request) (*http.Request, error) {
+	data := &templateData{
+		ID:      request.ID,
+		Attempt: strconv.Itoa(request.Attempt),
+		Params:  request.Params.Strings(),
+	}
+	target, err := render(job.url, data)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if job.method != http.MethodGet && job.method != http.MethodDelete {
+		var payload string
+		if job.body != nil {
+			payload, err = render(job.body, data)
+		} else {
+			payload = request.Params.String()
+		}
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(payload)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, job.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+	for header, tmpl := range job.headers {
+		value, err := render(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		httpRequest.Header.Set(header, value)
+	}
+	return httpRequest, nil
+}
+
+// Handle calls endpoint: 2xx is success, 408, 429, 5xx and configured statuses are retried, others are permanent errors
+func (job *webhookJob) Handle(request *protocol.Request) *protocol.Response {
+	ctx := request.Context()
+	workerID := WorkerID(ctx)
+	if job.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.timeout)
+		defer cancel()
+	}
+	httpRequest, err := job.build(ctx, request)
+	if err != nil {
+		return protocol.NewError(request, "3", err.Error(), true)
+	}
+	var httpResponse *http.Response
+	err = fault.Send("worker.webhook.call", func() error {
+		var err error
+		httpResponse, err = FromContext(ctx).HTTP.Do(httpRequest)
+		return err
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event":   "webhook_failed",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"method":  job.method,
+			"attempt": request.Attempt,
+		}).Error(err)
+		return protocol.NewError(request, "1", err.Error(), false)
+	}
+	defer httpResponse.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(httpResponse.Body, maxResponseBody))
+	status := httpResponse.StatusCode
+	if status < 200 || status > 299 {
+		log.WithFields(log.Fields{
+			"event":   "webhook_error_status",
+			"worker":  workerID,
+			"taskID":  request.ID,
+			"method":  job.method,
+			"status":  status,
+			"attempt": request.Attempt,
+		}).Warn("endpoint returned error status")
+		return protocol.NewError(request, strconv.Itoa(status), string(bytes.TrimSpace(body)), !job.retryable(status))
+	}
+	log.WithFields(log.Fields{
+		"event":   "webhook_processed",
+		"worker":  workerID,
+		"taskID":  request.ID,
+		"method":  job.method,
+		"status":  status,
+		"attempt": request.Attempt,
+	}).Info("successfully call endpoint")
+	return protocol.NewResult(request, map[string]string{
+		"result": "success",
+		"status": strconv.Itoa(status),
+		"body":   string(bytes.TrimSpace(body)),
+	})
+}
+
+// webhooks returns handlers of configured webhooks
+func webhooks(appCfg *config.AppConfig) (map[storage.Action]Handler, error) {
+	handlers := map[storage.Action]Handler{}
+	for name, webhook := range appCfg.Worker.Webhooks {
+		action, err := storage.ParseAction(name)
+		if err != nil {
+			return nil, fmt.Errorf("worker.webhooks: %w", err)
+		}
+		job, err := newWebhook(name, webhook)
+		if err != nil {
+			return nil, fmt.Errorf("worker.webhooks.%s: %w", name, err)
+		}
+		handlers[action] = job.Handle
+	}
+	return handlers, nil
+}
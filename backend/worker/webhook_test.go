@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/freundallein/scheduler/backend/chassis/config"
+	"github.com/freundallein/scheduler/backend/chassis/protocol"
+)
+
+func TestWebhookStatuses(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		retry     []int
+		success   bool
+		permanent bool
+	}{
+		{name: "ok", status: http.StatusOK, success: true},
+		{name: "created", status: http.StatusCreated, success: true},
+		{name: "no content", status: http.StatusNoContent, success: true},
+		{name: "bad request", status: http.StatusBadRequest, permanent: true},
+		{name: "not found", status: http.StatusNotFound, permanent: true},
+		{name: "request timeout", status: http.StatusRequestTimeout},
+		{name: "too many requests", status: http.StatusTooManyRequests},
+		{name: "internal error", status: http.StatusInternalServerError},
+		{name: "unavailable", status: http.StatusServiceUnavailable},
+		{name: "configured retry", status: http.StatusConflict, retry: []int{http.StatusConflict}},
+		{name: "not modified", status: http.StatusNotModified, permanent: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(" reply \n"))
+			}))
+			defer server.Close()
+			job, err := newWebhook("call", config.Webhook{URL: server.URL, Retry: test.retry})
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := &protocol.Request{ID: "42", Attempt: 2, Params: protocol.Payload{}}
+			response := job.Handle(request)
+			if response.Attempt != 2 {
+				t.Errorf("attempt = %d, want 2", response.Attempt)
+			}
+			if test.success {
+				if len(response.Error) > 0 {
+					t.Fatalf("error = %s, want success", response.Error)
+				}
+				if response.Result.Get("status") != strconv.Itoa(test.status) {
+					t.Errorf("status = %s, want %d", response.Result.Get("status"), test.status)
+				}
+				if test.status != http.StatusNoContent && response.Result.Get("body") != "reply" {
+					t.Errorf("body = %q, want %q", response.Result.Get("body"), "reply")
+				}
+				return
+			}
+			if len(response.Error) == 0 {
+				t.Fatalf("result = %s, want error", response.Result)
+			}
+			if response.Error.Get("code") != strconv.Itoa(test.status) {
+				t.Errorf("code = %s, want %d", response.Error.Get("code"), test.status)
+			}
+			if response.Permanent() != test.permanent {
+				t.Errorf("permanent = %v, want %v", response.Permanent(), test.permanent)
+			}
+		})
+	}
+}
+
+func TestWebhookNetworkErrorIsRetried(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	job, err := newWebhook("call", config.Webhook{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := job.Handle(&protocol.Request{ID: "42", Params: protocol.Payload{}})
+	if response.Error.Get("code") != "1" || response.Permanent() {
+		t.Errorf("error = %s, want retryable code 1", response.Error)
+	}
+}
+
+func TestWebhookRequest(t *testing.T) {
+	var method, path, header, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bin, _ := ioutil.ReadAll(r.Body)
+		method, path, header, body = r.Method, r.URL.EscapedPath(), r.Header.Get("X-Attempt"), string(bin)
+	}))
+	defer server.Close()
+	job, err := newWebhook("call", config.Webhook{
+		Method:  "put",
+		URL:     server.URL + "/objects/{{path .Params.objectID}}",
+		Headers: map[string]string{"X-Attempt": "{{.Attempt}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := protocol.NewPayload(map[string]string{"objectID": "a/23"})
+	job.Handle(&protocol.Request{ID: "42", Attempt: 3, Params: params})
+	if method != http.MethodPut || path != "/objects/a%2F23" || header != "3" || body != `{"objectID":"a/23"}` {
+		t.Errorf("got %s %s attempt=%s body=%s", method, path, header, body)
+	}
+}
@@ -10,6 +10,9 @@ create table if not exists t_scheduler (
     result  jsonb not null default '{}'::jsonb,
     error  jsonb not null default '{}'::jsonb,
     attempts integer not null default 0,
+    trace jsonb not null default '{}'::jsonb,
+    callback jsonb not null default '{}'::jsonb,
+    priority integer not null default 0,
     delayed_dt timestamp null default localtimestamp,
     created_dt timestamp not null default localtimestamp,
     updated_dt timestamp not null default localtimestamp
@@ -20,9 +23,43 @@ create table if not exists t_scheduler (
     fillfactor=30
 );
 
-create unique index concurrently if not exists scheduler_object_index ON t_scheduler( (payload->'objectID'), action ) WITH (fillfactor=30);
 create index concurrently task__state__delayed_dt__idx on t_scheduler (state, delayed_dt) WITH (fillfactor=30);
 
+alter table t_scheduler add column if not exists trace jsonb not null default '{}'::jsonb;
+alter table t_scheduler add column if not exists callback jsonb not null default '{}'::jsonb;
+alter table t_scheduler add column if not exists priority integer not null default 0;
+
+drop index concurrently if exists task__state__priority__idx;
+create index concurrently if not exists task__state__priority__id__idx on t_scheduler (state, priority desc, id) WITH (fillfactor=30);
+
+create table if not exists t_idempotency (
+    action varchar(32) not null,
+    key varchar(256) not null,
+    task_id integer not null,
+    expires_dt timestamp not null,
+    primary key (action, key)
+);
+
+-- Permanent objectID uniqueness is replaced by t_idempotency's window
+drop index concurrently if exists scheduler_object_index;
+
+-- Outbox of finished tasks' callbacks, rows are kept as delivery log until supervisor's expiration
+create table if not exists t_callback (
+    id serial primary key,
+    task_id integer not null,
+    callback jsonb not null,
+    payload jsonb not null,
+    state varchar(32) not null default 'PENDING',
+    attempts integer not null default 0,
+    status integer not null default 0,
+    error text not null default '',
+    delayed_dt timestamp not null default localtimestamp,
+    created_dt timestamp not null default localtimestamp,
+    updated_dt timestamp not null default localtimestamp
+);
+
+create index if not exists callback__state__delayed_dt__idx on t_callback (state, delayed_dt);
+
 create table if not exists t_object (
     id serial primary key unique,
     data jsonb not null default '{}'::jsonb,